@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// coverageFormats はカバレッジの出力形式
+var coverageFormats = map[string]func(cov *pac.Coverage, w io.Writer) error{
+	"lcov": (*pac.Coverage).WriteLCOV,
+	"text": (*pac.Coverage).WriteAnnotated,
+}
+
+// addCoverageFlags はPACスクリプトの実行回数の出力に関するオプションを登録する
+func (conf *config) addCoverageFlags(flags *flag.FlagSet) {
+	flags.StringVar(&conf.coverageFile, "coverage", "", "write line, branch and function coverage of proxy.pac to `file`")
+	flags.StringVar(&conf.coverageFormat, "coverage-format", "lcov", "coverage `format`: lcov (tracefile for genhtml etc.) or text (annotated source)")
+}
+
+// checkCoverageFlags は addCoverageFlags で登録したオプションの値を検査する
+func (conf *config) checkCoverageFlags() (err error) {
+	if _, ok := coverageFormats[conf.coverageFormat]; !ok {
+		err = fmt.Errorf("unknown coverage format: %s", conf.coverageFormat)
+		return
+	}
+	if conf.coverageFile != "" {
+		conf.coverage = &pac.Coverage{}
+	}
+	return
+}
+
+// writeCoverage は集計した実行回数をファイルに出力し、その概要を w に出力する
+func writeCoverage(conf config, w io.Writer) (err error) {
+	var f *os.File
+	f, err = os.Create(conf.coverageFile)
+	if err != nil {
+		return
+	}
+	err = coverageFormats[conf.coverageFormat](conf.coverage, f)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "coverage: %s\n", conf.coverage.Summary())
+	return
+}
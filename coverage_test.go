@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProcessTestCoverage(t *testing.T) {
+	dir := t.TempDir()
+	pacFile := filepath.Join(dir, "proxy.pac")
+	err := os.WriteFile(pacFile, []byte(`function FindProxyForURL(url, host) {
+    if (isPlainHostName(host)) {
+        return "DIRECT";
+    }
+    if (dnsDomainIs(host, ".dead.example")) {
+        return "PROXY dead:8080";
+    }
+    return "PROXY proxy:8080";
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := readCases([]byte(`
+- url: http://intra/
+  expect: DIRECT
+- url: http://www.example.com/
+  expect: PROXY proxy:8080
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := config{proxyPac: pacFile, coverageFile: filepath.Join(dir, "coverage.txt"), coverageFormat: "text"}
+	err = conf.checkCoverageFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	err = processTest(conf, cases, "", &buf)
+	if err != nil {
+		t.Fatalf("processTest() = %v; want nil", err)
+	}
+	if !strings.Contains(buf.String(), "coverage: lines 5/6 (83.3%), branches 3/4 (75.0%), functions 1/1 (100.0%)\n") {
+		t.Errorf("processTest() output:\n%s", buf.String())
+	}
+
+	b, err := os.ReadFile(conf.coverageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "    #####:    6:        return \"PROXY dead:8080\";\n") {
+		t.Errorf("coverage =\n%s", b)
+	}
+
+	conf.coverageFormat = "html"
+	if err = conf.checkCoverageFlags(); err == nil {
+		t.Errorf("checkCoverageFlags(html) = nil; want error")
+	}
+}
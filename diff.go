@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+const (
+	diffUsageFmt = "%s [options] old.pac new.pac [url...]\n"
+)
+
+// 差分の出力形式
+var diffFormats = []string{"text", "json"}
+
+// urlDiff は新旧のPACスクリプトで評価結果が異なるURL
+type urlDiff struct {
+	URL string `json:"url"`
+	Old record `json:"old"`
+	New record `json:"new"`
+}
+
+// diffSummary は差分の件数
+type diffSummary struct {
+	URLs      int `json:"urls"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// routing は比較に使用する評価結果
+// 解析したプロキシのリストで比較し、エラーはメッセージによらず同じとみなす
+func (rec record) routing() string {
+	if rec.Error != "" && len(rec.Proxies) < 1 {
+		return "error"
+	}
+	return rec.proxies()
+}
+
+// display は差分として表示する評価結果
+func (rec record) display() string {
+	if rec.Error != "" && len(rec.Proxies) < 1 {
+		return "error: " + rec.Error
+	}
+	return rec.proxies()
+}
+
+// runDiff は2つのPACスクリプトで同じURLを評価し、結果が異なるURLを出力する
+func runDiff(name string, args []string) (exitCode int) {
+	var conf config
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), diffUsageFmt, name)
+		flags.PrintDefaults()
+	}
+	conf.addPACFlags(flags)
+	flags.StringVar(&conf.input, "i", "", "read URLs from `file`, one per line (\"-\" for stdin)")
+	flags.StringVar(&conf.format, "format", "text", "output `format`: "+strings.Join(diffFormats, "|"))
+
+	err := flags.Parse(args)
+	if err == nil && flags.NArg() < 2 {
+		flags.Usage()
+		err = flag.ErrHelp
+	}
+	if err == nil && conf.wpad {
+		err = fmt.Errorf("-wpad cannot be used with diff")
+	}
+	if err == nil && conf.format != "text" && conf.format != "json" {
+		err = fmt.Errorf("unknown format: %s", conf.format)
+	}
+	if err == nil {
+		err = conf.checkPACFlags()
+	}
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		exitCode = argumentErr
+		return
+	}
+	conf.urls = flags.Args()[2:]
+
+	err = processDiff(conf, flags.Arg(0), flags.Arg(1), os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = runtimeErr
+	}
+	return
+}
+
+// processDiff は新旧のPACスクリプトを同じ条件で読み込み、全てのURLを評価して差分を出力する
+// 時刻とDNSの違いで差分が出ないよう、-at を指定しない場合も開始時の時刻に固定し、
+// 名前解決の結果は2つのスクリプトで共有する
+func processDiff(conf config, oldPac, newPac string, w io.Writer) (err error) {
+	ctx := context.Background()
+
+	if conf.at.IsZero() {
+		conf.at = time.Now()
+	}
+	var resolver pac.Resolver = net.DefaultResolver
+	if conf.resolver != nil {
+		resolver = conf.resolver
+	}
+	conf.resolver = pac.NewCachingResolver(resolver)
+
+	var oldP, newP *pac.PAC
+	conf.proxyPac = oldPac
+	oldP, err = loadPAC(ctx, conf)
+	if err != nil {
+		return
+	}
+	conf.proxyPac = newPac
+	newP, err = loadPAC(ctx, conf)
+	if err != nil {
+		return
+	}
+
+	var diffs []urlDiff
+	var summary diffSummary
+	err = forEachURL(conf, func(urlStr string) error {
+		summary.URLs++
+		d := urlDiff{
+			URL: urlStr,
+			Old: evaluate(ctx, oldP, urlStr),
+			New: evaluate(ctx, newP, urlStr),
+		}
+		if d.Old.routing() == d.New.routing() {
+			summary.Unchanged++
+			return nil
+		}
+		summary.Changed++
+		diffs = append(diffs, d)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = writeDiff(conf.format, w, diffs, summary)
+	return
+}
+
+// writeDiff は結果が異なるURLと件数を出力する
+func writeDiff(format string, w io.Writer, diffs []urlDiff, summary diffSummary) (err error) {
+	if format == "json" {
+		if diffs == nil {
+			diffs = []urlDiff{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Changed []urlDiff   `json:"changed"`
+			Summary diffSummary `json:"summary"`
+		}{diffs, summary})
+		return
+	}
+
+	for _, d := range diffs {
+		_, err = fmt.Fprintf(w, "%s\n    - %s\n    + %s\n", d.URL, d.Old.display(), d.New.display())
+		if err != nil {
+			return
+		}
+	}
+	_, err = fmt.Fprintf(w, "%d urls: %d changed, %d unchanged\n", summary.URLs, summary.Changed, summary.Unchanged)
+	return
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldPac := filepath.Join(dir, "old.pac")
+	newPac := filepath.Join(dir, "new.pac")
+	err := os.WriteFile(oldPac, []byte(`
+function FindProxyForURL(url, host) {
+    if (dnsDomainIs(host, ".foo.co.jp")) {
+        return "PROXY proxy1:8000";
+    }
+    if (host == "broken") {
+        return foo();
+    }
+    return "PROXY proxy2:8080; DIRECT";
+}
+`), 0644)
+	if err == nil {
+		err = os.WriteFile(newPac, []byte(`
+function FindProxyForURL(url, host) {
+    if (dnsDomainIs(host, ".foo.co.jp")) {
+        return "DIRECT";
+    }
+    if (host == "broken") {
+        return bar();
+    }
+    return "PROXY proxy2:8080;DIRECT";
+}
+`), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := config{urls: []string{"http://www.foo.co.jp/", "http://hoge.com/", "http://broken/"}}
+	var buf bytes.Buffer
+	err = processDiff(conf, oldPac, newPac, &buf)
+	want := "http://www.foo.co.jp/\n" +
+		"    - PROXY proxy1:8000\n" +
+		"    + DIRECT\n" +
+		"3 urls: 1 changed, 2 unchanged\n"
+	if err != nil || buf.String() != want {
+		t.Errorf("processDiff() = %v\n%s\nwant nil\n%s", err, buf.String(), want)
+	}
+}
+
+// roundRobinResolver は呼び出すたびに異なるアドレスを返すリゾルバ
+type roundRobinResolver struct {
+	n *int
+}
+
+func (r roundRobinResolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	*r.n++
+	addrs = []string{fmt.Sprintf("10.0.0.%d", *r.n)}
+	return
+}
+
+func TestProcessDiffSameConditions(t *testing.T) {
+	// 同じスクリプトなら、ラウンドロビンのDNSで評価ごとにアドレスが変わっても差分は出ない
+	pacFile := filepath.Join(t.TempDir(), "proxy.pac")
+	err := os.WriteFile(pacFile, []byte(`
+function FindProxyForURL(url, host) {
+    return "PROXY " + dnsResolve(host) + ":8080";
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	conf := config{urls: []string{"http://hoge.com/", "http://fuga.com/"}, resolver: roundRobinResolver{&n}}
+	var buf bytes.Buffer
+	err = processDiff(conf, pacFile, pacFile, &buf)
+	if err != nil || buf.String() != "2 urls: 0 changed, 2 unchanged\n" {
+		t.Errorf("processDiff() = %v\n%s\nwant nil\n2 urls: 0 changed, 2 unchanged", err, buf.String())
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// slowestURLs は名前解決の報告に出力するURLの数
+const slowestURLs = 10
+
+// dnsLookup は評価中に組み込み関数が行った名前解決の記録
+type dnsLookup struct {
+	Func       string   `json:"func"`
+	Host       string   `json:"host"`
+	Addrs      []string `json:"addrs"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS float64  `json:"duration_ms"`
+	Caller     string   `json:"caller,omitempty"`
+	Line       int      `json:"line,omitempty"`
+}
+
+func newDNSLookup(l pac.Lookup) (r dnsLookup) {
+	r = dnsLookup{
+		Func:       l.Func,
+		Host:       l.Host,
+		Addrs:      l.Addrs,
+		DurationMS: float64(l.Duration) / float64(time.Millisecond),
+		Caller:     l.Caller,
+		Line:       l.Line,
+	}
+	if l.Err != nil {
+		r.Error = l.Err.Error()
+	}
+	if r.Addrs == nil {
+		r.Addrs = []string{}
+	}
+	return
+}
+
+// String は `isInNet("intra") -> 10.1.2.3 (12.345ms, line 6 in FindProxyForURL)` の形式で記録を返す
+func (l dnsLookup) String() string {
+	result := strings.Join(l.Addrs, ";")
+	if l.Error != "" {
+		result = "error: " + l.Error
+	}
+	return fmt.Sprintf("%s(%q) -> %s (%.3fms, %s)", l.Func, l.Host, result, l.DurationMS, l.rule())
+}
+
+// rule は名前解決を呼び出したスクリプトの位置を返す
+func (l dnsLookup) rule() string {
+	r := fmt.Sprintf("line %d", l.Line)
+	if l.Caller != "" {
+		r += " in " + l.Caller
+	}
+	return r
+}
+
+// dnsRule は名前解決を呼び出したスクリプトの位置と組み込み関数ごとの集計
+type dnsRule struct {
+	line     int
+	fn       string
+	caller   string
+	lookups  int
+	urls     int
+	duration float64
+}
+
+// dnsURL はURLごとの名前解決の集計
+type dnsURL struct {
+	url      string
+	lookups  int
+	duration float64
+}
+
+// dnsProfile はURLごとの名前解決の記録を集計する
+type dnsProfile struct {
+	name      string
+	urls      int
+	resolving int
+	lookups   int
+	duration  float64
+	rules     map[string]*dnsRule
+	slowest   []dnsURL
+}
+
+func newDNSProfile(name string) *dnsProfile {
+	return &dnsProfile{name: name, rules: map[string]*dnsRule{}}
+}
+
+// add は1つのURLの名前解決の記録を集計する
+func (dp *dnsProfile) add(rec record) {
+	dp.urls++
+	if len(rec.Lookups) < 1 {
+		return
+	}
+	dp.resolving++
+
+	u := dnsURL{url: rec.URL}
+	seen := map[string]bool{}
+	for _, l := range rec.Lookups {
+		key := fmt.Sprintf("%d:%s:%s", l.Line, l.Func, l.Caller)
+		r := dp.rules[key]
+		if r == nil {
+			r = &dnsRule{line: l.Line, fn: l.Func, caller: l.Caller}
+			dp.rules[key] = r
+		}
+		r.lookups++
+		r.duration += l.DurationMS
+		if !seen[key] {
+			seen[key] = true
+			r.urls++
+		}
+		u.lookups++
+		u.duration += l.DurationMS
+	}
+	dp.lookups += u.lookups
+	dp.duration += u.duration
+
+	// 名前解決を最も長く待ったURLだけを残す
+	dp.slowest = append(dp.slowest, u)
+	sort.SliceStable(dp.slowest, func(i, j int) bool {
+		return dp.slowest[i].duration > dp.slowest[j].duration
+	})
+	if len(dp.slowest) > slowestURLs {
+		dp.slowest = dp.slowest[:slowestURLs]
+	}
+}
+
+// write は名前解決を呼び出した位置ごとの回数と時間、名前解決を長く待ったURLを出力する
+func (dp *dnsProfile) write(w io.Writer) (err error) {
+	_, err = fmt.Fprintf(w, "dns profile: %d of %d urls made DNS lookups, %d lookups, %.3fms blocking in total\n",
+		dp.resolving, dp.urls, dp.lookups, dp.duration)
+	if err != nil || dp.lookups < 1 {
+		return
+	}
+
+	rules := make([]*dnsRule, 0, len(dp.rules))
+	for _, r := range dp.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].duration != rules[j].duration {
+			return rules[i].duration > rules[j].duration
+		}
+		return rules[i].line < rules[j].line
+	})
+
+	var b strings.Builder
+	b.WriteString("rules:\n")
+	for _, r := range rules {
+		rule := fmt.Sprintf("%s:%d %s", dp.name, r.line, r.fn)
+		if r.caller != "" {
+			rule += " in " + r.caller
+		}
+		fmt.Fprintf(&b, "  %6d lookups %6d urls %10.3fms total %9.3fms avg  %s\n",
+			r.lookups, r.urls, r.duration, r.duration/float64(r.lookups), rule)
+	}
+	b.WriteString("slowest urls:\n")
+	for _, u := range dp.slowest {
+		fmt.Fprintf(&b, "  %6d lookups %10.3fms  %s\n", u.lookups, u.duration, u.url)
+	}
+	_, err = io.WriteString(w, b.String())
+	return
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDNSProfile(t *testing.T) {
+	dp := newDNSProfile("proxy.pac")
+	dp.add(record{URL: "http://intra/", Lookups: []dnsLookup{
+		{Func: "isInNet", Host: "intra", DurationMS: 10, Caller: "isIntra", Line: 2},
+		{Func: "isInNet", Host: "intra", DurationMS: 20, Caller: "isIntra", Line: 2},
+		{Func: "dnsResolve", Host: "intra", DurationMS: 5, Caller: "FindProxyForURL", Line: 6},
+	}})
+	dp.add(record{URL: "http://www/"})
+	dp.add(record{URL: "http://hoge/", Lookups: []dnsLookup{
+		{Func: "isInNet", Host: "hoge", DurationMS: 40, Caller: "isIntra", Line: 2},
+	}})
+
+	var buf bytes.Buffer
+	err := dp.write(&buf)
+	if err != nil {
+		t.Fatalf("write() = %v; want nil", err)
+	}
+	want := `dns profile: 2 of 3 urls made DNS lookups, 4 lookups, 75.000ms blocking in total
+rules:
+       3 lookups      2 urls     70.000ms total    23.333ms avg  proxy.pac:2 isInNet in isIntra
+       1 lookups      1 urls      5.000ms total     5.000ms avg  proxy.pac:6 dnsResolve in FindProxyForURL
+slowest urls:
+       1 lookups     40.000ms  http://hoge/
+       3 lookups     35.000ms  http://intra/
+`
+	if buf.String() != want {
+		t.Errorf("write() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	l := dp.rules["2:isInNet:isIntra"]
+	if l == nil || l.urls != 2 {
+		t.Errorf("rules = %v; want isInNet at line 2 for 2 urls", dp.rules)
+	}
+	s := dnsLookup{Func: "isInNet", Host: "intra", Addrs: []string{"10.1.2.3"}, DurationMS: 12.3456, Caller: "FindProxyForURL", Line: 6}.String()
+	if s != `isInNet("intra") -> 10.1.2.3 (12.346ms, line 6 in FindProxyForURL)` {
+		t.Errorf("String() = %s", s)
+	}
+}
@@ -0,0 +1,14 @@
+module github.com/bunji2/findproxy
+
+go 1.25.0
+
+require (
+	github.com/robertkrimen/otto v0.2.1
+	golang.org/x/net v0.57.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/text v0.40.0 // indirect
+	gopkg.in/sourcemap.v1 v1.0.5 // indirect
+)
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxURLLen は入力ファイルの1行の最大長
+const maxURLLen = 1024 * 1024
+
+// forEachURL はコマンドライン引数と入力ファイルのURLを順番に fn に渡す
+// 入力ファイルが "-" の場合は標準入力から読み込む
+func forEachURL(conf config, fn func(urlStr string) error) (err error) {
+	for _, urlStr := range conf.urls {
+		err = fn(urlStr)
+		if err != nil {
+			return
+		}
+	}
+
+	if conf.input == "" {
+		return
+	}
+
+	r := io.Reader(os.Stdin)
+	if conf.input != "-" {
+		var f *os.File
+		f, err = os.Open(conf.input)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	err = scanURLs(r, fn)
+	return
+}
+
+// scanURLs は r から1行に1つのURLを読み込み fn に渡す
+// 空行と '#' で始まるコメント行は読み飛ばす
+func scanURLs(r io.Reader, fn func(urlStr string) error) (err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxURLLen)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err = fn(line)
+		if err != nil {
+			return
+		}
+	}
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanURLs(t *testing.T) {
+	input := "# urls\r\nhttp://hoge.com/hoge\r\n\r\n  http://hogehoge/  \n   # indented comment\nhttp://www.foo.co.jp/#top"
+	want := []string{"http://hoge.com/hoge", "http://hogehoge/", "http://www.foo.co.jp/#top"}
+
+	var got []string
+	err := scanURLs(strings.NewReader(input), func(urlStr string) error {
+		got = append(got, urlStr)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("scanURLs() = %q, %v; want %q, nil", got, err, want)
+	}
+}
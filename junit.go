@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JUnit XML の形式のテスト結果
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// newJUnitReport はテストケースの結果から JUnit XML のテスト結果を生成する
+// 結果が期待と異なる場合は failure、評価に失敗した場合は error とする
+func newJUnitReport(name string, results []caseResult) (r junitTestSuites) {
+	suite := junitTestSuite{
+		Name:  name,
+		Tests: len(results),
+	}
+	var total time.Duration
+	for _, cr := range results {
+		total += cr.Duration
+		tc := junitTestCase{
+			Name:      cr.Case.Name,
+			Classname: name,
+			Time:      junitTime(cr.Duration),
+		}
+		switch {
+		case cr.Error != "":
+			suite.Errors++
+			tc.Error = &junitMessage{
+				Message: cr.Error,
+				Text:    "url: " + cr.Case.URL + "\nexpect: " + cr.Case.Expect + "\nerror: " + cr.Error + "\n",
+			}
+		case cr.Diff != nil:
+			suite.Failures++
+			tc.Failure = &junitMessage{
+				Message: fmt.Sprintf("expected %q, got %q", cr.Case.Expect, cr.Got),
+				Text:    "url: " + cr.Case.URL + "\n" + strings.Join(cr.Diff, "\n") + "\n",
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitTime(total)
+	r.Suites = []junitTestSuite{suite}
+	return
+}
+
+// writeJUnitFile は JUnit XML のテスト結果をファイルに出力する
+func writeJUnitFile(filePath, name string, results []caseResult) (err error) {
+	var bb []byte
+	bb, err = xml.MarshalIndent(newJUnitReport(name, results), "", "  ")
+	if err != nil {
+		return
+	}
+	bb = append([]byte(xml.Header), bb...)
+	bb = append(bb, '\n')
+	err = os.WriteFile(filePath, bb, 0644)
+	return
+}
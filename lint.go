@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+const (
+	lintUsageFmt = "%s [options] proxy.pac...\n"
+)
+
+// lintIssue はPACファイルの問題の出力形式
+type lintIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// runLint はPACファイルを実行せずに調べ、見つかった問題を出力する
+func runLint(name string, args []string) (exitCode int) {
+	var conf config
+	var strict bool
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), lintUsageFmt, name)
+		flags.PrintDefaults()
+	}
+	conf.addFetchFlags(flags)
+	flags.BoolVar(&strict, "strict", false, "exit with an error status on warnings too")
+	flags.StringVar(&conf.format, "format", "text", "output `format`: text|json")
+
+	err := flags.Parse(args)
+	if err == nil && flags.NArg() < 1 {
+		flags.Usage()
+		err = flag.ErrHelp
+	}
+	if err == nil && conf.format != "text" && conf.format != "json" {
+		err = fmt.Errorf("unknown format: %s", conf.format)
+	}
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		exitCode = argumentErr
+		return
+	}
+
+	err = processLint(conf, flags.Args(), strict, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = runtimeErr
+	}
+	return
+}
+
+// processLint は全てのPACファイルを調べて問題を出力する
+// エラー (strict の場合は警告も) が見つかった場合はその件数をエラーとして返す
+func processLint(conf config, files []string, strict bool, w io.Writer) (err error) {
+	ctx := context.Background()
+
+	issues := []lintIssue{}
+	var errs, warnings int
+	for _, location := range files {
+		var src []byte
+		src, err = readPAC(ctx, conf, location)
+		if err != nil {
+			return
+		}
+		for _, i := range pac.Lint(location, src) {
+			if i.Severity == pac.SeverityError {
+				errs++
+			} else {
+				warnings++
+			}
+			issues = append(issues, lintIssue{location, i.Line, i.Column, i.Severity.String(), i.Message})
+		}
+	}
+
+	if conf.format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(issues)
+	} else {
+		for _, i := range issues {
+			_, err = fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", i.File, i.Line, i.Column, i.Severity, i.Message)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if errs > 0 || strict && warnings > 0 {
+		err = fmt.Errorf("%d errors, %d warnings", errs, warnings)
+	}
+	return
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessLint(t *testing.T) {
+	dir := t.TempDir()
+	pacFile := filepath.Join(dir, "proxy.pac")
+	err := os.WriteFile(pacFile, []byte(`function FindProxyForURL(url, host) {
+    if (dnsDomainIs(host, "foo.co.jp")) {
+        return "DIRECT";
+    }
+    return "PROXY proxy:8080";
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = processLint(config{format: "text"}, []string{pacFile}, false, &buf)
+	if err != nil {
+		t.Errorf("processLint() = %v; want nil", err)
+	}
+	want := pacFile + `:2:27: warning: dnsDomainIs domain "foo.co.jp" has no leading dot; it also matches hosts like "xfoo.co.jp" (use ".foo.co.jp")` + "\n"
+	if buf.String() != want {
+		t.Errorf("processLint() output = %q; want %q", buf.String(), want)
+	}
+
+	// -strict では警告もエラーにする
+	buf.Reset()
+	err = processLint(config{format: "json"}, []string{pacFile}, true, &buf)
+	if err == nil || err.Error() != "0 errors, 1 warnings" {
+		t.Errorf("processLint(strict) = %v; want 0 errors, 1 warnings", err)
+	}
+	var issues []lintIssue
+	err = json.Unmarshal(buf.Bytes(), &issues)
+	if err != nil || len(issues) != 1 || issues[0].Line != 2 || issues[0].Severity != "warning" {
+		t.Errorf("processLint(json) = %s, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bunji2/findproxy/pac"
+	"github.com/bunji2/findproxy/wpad"
+)
+
+// maxRedirects はPACファイルの取得を中止するリダイレクトの回数 (net/http の既定と同じ)
+const maxRedirects = 10
+
+// defaultCacheDir は取得したPACファイルをキャッシュする既定のディレクトリを返す
+func defaultCacheDir() (r string) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return
+	}
+	r = filepath.Join(dir, "findproxy")
+	return
+}
+
+// loadPAC はローカルファイルまたはHTTP(S)のURLからPACスクリプトを読み込む
+func loadPAC(ctx context.Context, conf config) (p *pac.PAC, err error) {
+	if conf.wpad {
+		p, err = discoverPAC(ctx, conf)
+		return
+	}
+
+	if !pac.IsURL(conf.proxyPac) {
+		p, err = pac.LoadFile(conf.proxyPac, conf.pacOptions()...)
+		return
+	}
+
+	var f *pac.Fetcher
+	f, err = conf.fetcher()
+	if err != nil {
+		return
+	}
+	p, err = f.Load(ctx, conf.proxyPac, conf.pacOptions()...)
+	return
+}
+
+// readPAC はローカルファイルまたはHTTP(S)のURLからPACスクリプトのソースを読み込む
+func readPAC(ctx context.Context, conf config, location string) (src []byte, err error) {
+	if !pac.IsURL(location) {
+		src, err = os.ReadFile(location)
+		return
+	}
+
+	var f *pac.Fetcher
+	f, err = conf.fetcher()
+	if err != nil {
+		return
+	}
+	src, err = f.Fetch(ctx, location)
+	return
+}
+
+// fetcher は設定に応じてPACファイルを取得する Fetcher を生成する
+func (conf config) fetcher() (f *pac.Fetcher, err error) {
+	f, err = pac.NewFetcher(conf.fetchTimeout, maxRedirects, conf.caFile)
+	if err != nil {
+		return
+	}
+	f.CacheDir = conf.cacheDir
+	f.StrictContentType = conf.strictType
+	return
+}
+
+// discoverPAC はWPADでPACスクリプトを探して読み込む
+// DHCPのインターフェースかリースファイルが指定されている場合はDHCPを先に試し、
+// 見つからなければDNSで探す
+func discoverPAC(ctx context.Context, conf config) (p *pac.PAC, err error) {
+	var f *pac.Fetcher
+	f, err = conf.fetcher()
+	if err != nil {
+		return
+	}
+
+	if conf.dhcpIface != "" || conf.dhcpLease != "" {
+		var u string
+		u, err = dhcpWPADURL(ctx, conf)
+		if err == nil {
+			p, err = f.Load(ctx, u, conf.pacOptions()...)
+		}
+		if err == nil {
+			fmt.Fprintln(os.Stderr, "wpad (dhcp):", u)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "wpad (dhcp):", err)
+	}
+
+	var domains []string
+	domains, err = wpad.SearchDomains(conf.resolvConf)
+	if err != nil {
+		return
+	}
+
+	var used string
+	p, used, err = wpad.DiscoverDNS(ctx, f, wpad.Candidates(domains), conf.pacOptions()...)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "wpad (dns):", used)
+	return
+}
+
+// dhcpWPADURL はDHCPのオプション252からWPADのURLを取得する
+func dhcpWPADURL(ctx context.Context, conf config) (u string, err error) {
+	if conf.dhcpLease != "" {
+		u, err = wpad.LeaseURL(conf.dhcpLease)
+		return
+	}
+	c := &wpad.DHCPClient{
+		Interface: conf.dhcpIface,
+		Server:    conf.dhcpServer,
+	}
+	u, err = c.Inform(ctx)
+	return
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
+	"net"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+	"github.com/bunji2/findproxy/wpad"
 )
 
 const (
-	usageFmt = "%s proxy.pac url...\n"
+	usageFmt = "%[1]s [options] proxy.pac [url...]\n%[1]s -wpad [options] [url...]\n%[1]s serve [options]\n%[1]s api [options]\n%[1]s matrix [options] proxy.pac [url...]\n%[1]s test [options] proxy.pac cases.yaml\n%[1]s diff [options] old.pac new.pac [url...]\n%[1]s lint [options] proxy.pac...\n"
 )
 
 const (
@@ -15,21 +23,77 @@ const (
 	runtimeErr
 )
 
+// config はコマンドライン引数で指定された設定
+type config struct {
+	proxyPac   string
+	urls       []string
+	atStr      string
+	at         time.Time
+	parse      bool
+	format     string
+	input      string
+	jobs       int
+	trace      bool
+	dnsProfile bool
+
+	coverageFile   string
+	coverageFormat string
+	coverage       *pac.Coverage
+
+	timeout      time.Duration
+	fallback     string
+	myIPStr      string
+	myIP         []string
+	hostsFile    string
+	dnsServer    string
+	resolver     pac.Resolver
+	fetchTimeout time.Duration
+	caFile       string
+	strictType   bool
+	cacheDir     string
+
+	wpad       bool
+	resolvConf string
+	dhcpIface  string
+	dhcpLease  string
+	dhcpServer string
+
+	reloadInterval time.Duration
+	alertLogger    *log.Logger
+}
+
 func main() {
 	os.Exit(run())
 }
 
+// commands はサブコマンドの一覧
+var commands = map[string]func(name string, args []string) (exitCode int){
+	"serve":  runServe,
+	"api":    runAPI,
+	"matrix": runMatrix,
+	"test":   runTest,
+	"diff":   runDiff,
+	"lint":   runLint,
+}
+
 func run() (exitCode int) {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, usageFmt, os.Args[0])
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			exitCode = command(os.Args[0]+" "+os.Args[1], os.Args[2:])
+			return
+		}
+	}
+
+	conf, err := parseArgs(os.Args[0], os.Args[1:])
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		exitCode = argumentErr
 		return
 	}
 
-	proxyPac := os.Args[1]
-	urls := os.Args[2:]
-
-	err := process(proxyPac, urls)
+	err = process(conf)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		exitCode = runtimeErr
@@ -37,3 +101,166 @@ func run() (exitCode int) {
 
 	return
 }
+
+func parseArgs(name string, args []string) (conf config, err error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), usageFmt, name)
+		flags.PrintDefaults()
+	}
+	conf.addPACFlags(flags)
+	flags.BoolVar(&conf.parse, "parse", false, "print each entry of the result with its type, host and port (text format)")
+	flags.BoolVar(&conf.trace, "trace", false, "record every builtin function call with its arguments, result and duration for each URL")
+	flags.BoolVar(&conf.dnsProfile, "dns-profile", false, "record DNS lookups made by builtins for each URL and report the rules and URLs that make browsers wait for DNS")
+	flags.IntVar(&conf.jobs, "j", 1, "evaluate up to `N` URLs in parallel (output keeps the input order)")
+	flags.StringVar(&conf.input, "i", "", "read URLs from `file`, one per line (\"-\" for stdin)")
+	flags.StringVar(&conf.format, "format", "text", "output `format`: "+strings.Join(formats, "|"))
+	conf.addCoverageFlags(flags)
+
+	err = flags.Parse(args)
+	if err != nil {
+		return
+	}
+
+	if conf.wpad {
+		conf.urls = flags.Args()
+	} else if flags.NArg() < 1 {
+		flags.Usage()
+		err = flag.ErrHelp
+		return
+	} else {
+		conf.proxyPac = flags.Arg(0)
+		conf.urls = flags.Args()[1:]
+	}
+
+	if conf.jobs < 1 {
+		err = fmt.Errorf("abnormal -j: %d", conf.jobs)
+		return
+	}
+
+	if !subIsFormat(conf.format) {
+		err = fmt.Errorf("unknown format: %s", conf.format)
+		return
+	}
+
+	err = conf.checkPACFlags()
+	if err == nil {
+		err = conf.checkCoverageFlags()
+	}
+	return
+}
+
+// addPACFlags はPACスクリプトの読み込みと評価に関するオプションを登録する
+func (conf *config) addPACFlags(flags *flag.FlagSet) {
+	flags.StringVar(&conf.atStr, "at", "", "evaluate time-dependent builtins at the given `time` (RFC 3339, e.g. 2026-12-24T18:00:00+09:00)")
+	flags.DurationVar(&conf.timeout, "timeout", 10*time.Second, "time limit for running proxy.pac and for each FindProxyForURL call (0 for no limit)")
+	flags.StringVar(&conf.myIPStr, "my-ip", "", "`address[,address...]` returned by myIpAddress and myIpAddressEx instead of the local host's, to simulate a client elsewhere")
+	flags.StringVar(&conf.hostsFile, "hosts", "", "resolve host names for builtins from a hosts `file` instead of DNS (names not in it are not found unless -dns-server is given)")
+	flags.StringVar(&conf.dnsServer, "dns-server", "", "resolve host names for builtins by querying the DNS server at `address[:port]` instead of the system resolver")
+	flags.StringVar(&conf.fallback, "fallback", "", "`result` to use when proxy.pac fails to evaluate a URL, e.g. DIRECT like browsers (errors are still reported)")
+	conf.addFetchFlags(flags)
+
+	flags.BoolVar(&conf.wpad, "wpad", false, "discover proxy.pac by WPAD instead of giving it as an argument")
+	flags.StringVar(&conf.resolvConf, "resolv-conf", wpad.ResolvConf, "`file` to read the search domains from for WPAD")
+	flags.StringVar(&conf.dhcpIface, "dhcp-iface", "", "for WPAD, send DHCPINFORM on `interface` to get the URL from option 252 before trying DNS")
+	flags.StringVar(&conf.dhcpLease, "dhcp-lease", "", "for WPAD, read the option 252 URL from a dhclient or NetworkManager lease `file` before trying DNS")
+	flags.StringVar(&conf.dhcpServer, "dhcp-server", wpad.DefaultDHCPServer, "`address` to send DHCPINFORM to")
+}
+
+// addFetchFlags はHTTP(S)のURLで指定したPACファイルの取得に関するオプションを登録する
+func (conf *config) addFetchFlags(flags *flag.FlagSet) {
+	flags.DurationVar(&conf.fetchTimeout, "fetch-timeout", 30*time.Second, "timeout for fetching proxy.pac given as an http(s) URL")
+	flags.StringVar(&conf.caFile, "cacert", "", "additional CA certificates `file` (PEM) for fetching proxy.pac over https")
+	flags.BoolVar(&conf.strictType, "strict-content-type", false, "accept fetched proxy.pac only with Content-Type "+pac.MIMEType)
+	flags.StringVar(&conf.cacheDir, "cache-dir", defaultCacheDir(), "`directory` to cache fetched proxy.pac for conditional requests (empty to disable)")
+}
+
+// checkPACFlags は addPACFlags で登録したオプションの値を検査する
+func (conf *config) checkPACFlags() (err error) {
+	if conf.atStr != "" {
+		conf.at, err = time.Parse(time.RFC3339, conf.atStr)
+		if err != nil {
+			err = fmt.Errorf("abnormal time: %s", conf.atStr)
+			return
+		}
+	}
+	if conf.myIPStr != "" {
+		for _, addr := range strings.Split(conf.myIPStr, ",") {
+			addr = strings.TrimSpace(addr)
+			if net.ParseIP(addr) == nil {
+				err = fmt.Errorf("abnormal ip address: %s", addr)
+				return
+			}
+			conf.myIP = append(conf.myIP, addr)
+		}
+	}
+	err = conf.newResolver()
+	return
+}
+
+// newResolver は -hosts と -dns-server に応じて組み込み関数が使うリゾルバを生成する
+// 両方を指定した場合は hosts ファイルにない名前をDNSサーバに問い合わせる
+func (conf *config) newResolver() (err error) {
+	var resolvers pac.MultiResolver
+	if conf.hostsFile != "" {
+		var hosts pac.StaticResolver
+		hosts, err = pac.NewHostsResolver(conf.hostsFile)
+		if err != nil {
+			return
+		}
+		resolvers = append(resolvers, hosts)
+	}
+	if conf.dnsServer != "" {
+		resolvers = append(resolvers, pac.NewDNSResolver(conf.dnsServer))
+	}
+	switch len(resolvers) {
+	case 0:
+	case 1:
+		conf.resolver = resolvers[0]
+	default:
+		conf.resolver = resolvers
+	}
+	return
+}
+
+func subIsFormat(format string) (r bool) {
+	for _, f := range formats {
+		if f == format {
+			r = true
+			break
+		}
+	}
+	return
+}
+
+// pacOptions は設定に応じたPACの実行環境のオプションを返す
+func (conf config) pacOptions() (opts []pac.Option) {
+	if !conf.at.IsZero() {
+		opts = append(opts, pac.WithClock(pac.FixedClock(conf.at)))
+	}
+	if conf.timeout > 0 {
+		opts = append(opts, pac.WithTimeout(conf.timeout))
+	}
+	if len(conf.myIP) > 0 {
+		opts = append(opts, pac.WithMyIPAddress(conf.myIP...))
+	}
+	if conf.resolver != nil {
+		opts = append(opts, pac.WithResolver(conf.resolver))
+	}
+	if conf.fallback != "" {
+		opts = append(opts, pac.WithFallback(conf.fallback))
+	}
+	if conf.trace {
+		opts = append(opts, pac.WithTrace())
+	}
+	if conf.dnsProfile {
+		opts = append(opts, pac.WithDNSProfile())
+	}
+	if conf.alertLogger != nil {
+		opts = append(opts, pac.WithAlertLogger(conf.alertLogger))
+	}
+	if conf.coverage != nil {
+		opts = append(opts, pac.WithCoverage(conf.coverage))
+	}
+	return
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+const (
+	matrixUsageFmt = "%[1]s [options] proxy.pac [url...]\n%[1]s -wpad [options] [url...]\n"
+)
+
+// 拠点ごとの評価結果の出力形式
+var matrixFormats = []string{"text", "json", "csv", "tsv"}
+
+// site はクライアントの拠点
+type site struct {
+	Name string   `json:"name"`
+	IPs  []string `json:"my_ip"`
+}
+
+// siteFlag は -site name=ip[,ip...] を繰り返し指定するためのフラグ
+type siteFlag []site
+
+func (sf *siteFlag) String() string {
+	return ""
+}
+
+func (sf *siteFlag) Set(value string) (err error) {
+	var s site
+	s, err = parseSite(strings.Replace(value, "=", " ", 1))
+	if err != nil {
+		return
+	}
+	*sf = append(*sf, s)
+	return
+}
+
+// parseSite は "name ip[,ip...]" または "ip[,ip...]" の形式の拠点を解析する
+// 名前を省略した場合はIPアドレスを名前とする
+func parseSite(line string) (s site, err error) {
+	fields := strings.Fields(line)
+	switch len(fields) {
+	case 1:
+		s.Name = fields[0]
+	case 2:
+		s.Name = fields[0]
+		fields = fields[1:]
+	default:
+		err = fmt.Errorf("abnormal site: %s", line)
+		return
+	}
+	for _, addr := range strings.Split(fields[0], ",") {
+		if net.ParseIP(addr) == nil {
+			err = fmt.Errorf("abnormal ip address: %s", addr)
+			return
+		}
+		s.IPs = append(s.IPs, addr)
+	}
+	return
+}
+
+// readSites は1行に1つの拠点を "name ip[,ip...]" の形式で読み込む
+// 空行と '#' で始まるコメント行は読み飛ばす
+func readSites(r io.Reader) (sites []site, err error) {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var s site
+		s, err = parseSite(line)
+		if err != nil {
+			err = fmt.Errorf("line %d: %v", lineNo, err)
+			return
+		}
+		sites = append(sites, s)
+	}
+	err = scanner.Err()
+	return
+}
+
+// matrixRow は1つのURLの拠点ごとの評価結果
+type matrixRow struct {
+	URL     string        `json:"url"`
+	Results []matrixEntry `json:"results"`
+}
+
+// matrixEntry は1つの拠点での評価結果
+type matrixEntry struct {
+	Site    string      `json:"site"`
+	Result  string      `json:"result"`
+	Proxies []pac.Proxy `json:"proxies"`
+	Error   string      `json:"error,omitempty"`
+	Alerts  []string    `json:"alerts,omitempty"`
+}
+
+// cell は表に出力する評価結果
+func (me matrixEntry) cell() string {
+	if me.Error != "" {
+		return "error: " + me.Error
+	}
+	return me.Result
+}
+
+// runMatrix は拠点ごとにmyIpAddressを切り替えてURLを評価し、結果を表で出力する
+func runMatrix(name string, args []string) (exitCode int) {
+	var conf config
+	var sites siteFlag
+	var sitesFile string
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), matrixUsageFmt, name)
+		flags.PrintDefaults()
+	}
+	conf.addPACFlags(flags)
+	flags.Var(&sites, "site", "client site as `name=ip[,ip...]` (repeatable)")
+	flags.StringVar(&sitesFile, "sites", "", "read client sites from `file`, one \"name ip[,ip...]\" per line")
+	flags.StringVar(&conf.input, "i", "", "read URLs from `file`, one per line (\"-\" for stdin)")
+	flags.StringVar(&conf.format, "format", "text", "output `format`: "+strings.Join(matrixFormats, "|"))
+
+	err := flags.Parse(args)
+	if err == nil {
+		if conf.wpad {
+			conf.urls = flags.Args()
+		} else if flags.NArg() < 1 {
+			flags.Usage()
+			err = flag.ErrHelp
+		} else {
+			conf.proxyPac = flags.Arg(0)
+			conf.urls = flags.Args()[1:]
+		}
+	}
+	if err == nil && !subIsMatrixFormat(conf.format) {
+		err = fmt.Errorf("unknown format: %s", conf.format)
+	}
+	if err == nil && sitesFile != "" {
+		var f *os.File
+		f, err = os.Open(sitesFile)
+		if err == nil {
+			var fileSites []site
+			fileSites, err = readSites(f)
+			f.Close()
+			sites = append(fileSites, sites...)
+		}
+	}
+	if err == nil && len(sites) < 1 {
+		err = fmt.Errorf("-site or -sites is required")
+	}
+	if err == nil {
+		err = conf.checkPACFlags()
+	}
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		exitCode = argumentErr
+		return
+	}
+
+	err = processMatrix(conf, sites, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = runtimeErr
+	}
+	return
+}
+
+func subIsMatrixFormat(format string) (r bool) {
+	for _, f := range matrixFormats {
+		if f == format {
+			r = true
+			break
+		}
+	}
+	return
+}
+
+// processMatrix はPACスクリプトを拠点ごとにコンパイルし、全てのURLを評価して出力する
+func processMatrix(conf config, sites []site, w io.Writer) (err error) {
+	ctx := context.Background()
+
+	// PACスクリプトの取得は一度だけ行う
+	var p *pac.PAC
+	p, err = loadPAC(ctx, conf)
+	if err != nil {
+		return
+	}
+
+	pacs := make([]*pac.PAC, len(sites))
+	for i, s := range sites {
+		opts := append(conf.pacOptions(), pac.WithMyIPAddress(s.IPs...))
+		pacs[i], err = pac.Compile(p.Name(), p.Source(), opts...)
+		if err != nil {
+			return
+		}
+	}
+
+	var rows []matrixRow
+	failed := 0
+	err = forEachURL(conf, func(urlStr string) error {
+		row := matrixRow{URL: urlStr}
+		for i, s := range sites {
+			rec := evaluate(ctx, pacs[i], urlStr)
+			if rec.Error != "" {
+				failed++
+			}
+			if conf.format != "json" {
+				logAlerts(os.Stderr, s.Name+": ", rec.Alerts)
+				rec.Alerts = nil
+			}
+			row.Results = append(row.Results, matrixEntry{
+				Site:    s.Name,
+				Result:  rec.Result,
+				Proxies: rec.Proxies,
+				Error:   rec.Error,
+				Alerts:  rec.Alerts,
+			})
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = writeMatrix(conf.format, w, sites, rows)
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d of %d evaluations failed", failed, len(rows)*len(sites))
+	}
+	return
+}
+
+// writeMatrix はURLを行、拠点を列とした表を出力する
+func writeMatrix(format string, w io.Writer, sites []site, rows []matrixRow) (err error) {
+	if format == "json" {
+		if rows == nil {
+			rows = []matrixRow{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Sites []site      `json:"sites"`
+			Rows  []matrixRow `json:"rows"`
+		}{sites, rows})
+		return
+	}
+
+	header := []string{"url"}
+	for _, s := range sites {
+		header = append(header, s.Name)
+	}
+	table := [][]string{header}
+	for _, row := range rows {
+		line := []string{row.URL}
+		for _, entry := range row.Results {
+			line = append(line, entry.cell())
+		}
+		table = append(table, line)
+	}
+
+	if format == "text" {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, line := range table {
+			fmt.Fprintln(tw, strings.Join(line, "\t"))
+		}
+		err = tw.Flush()
+		return
+	}
+
+	cw := csv.NewWriter(w)
+	if format == "tsv" {
+		cw.Comma = '\t'
+	}
+	err = cw.WriteAll(table)
+	return
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSites(t *testing.T) {
+	input := "# sites\ntokyo 10.1.0.10\n\nosaka 10.2.0.10,2001:db8::10\n192.0.2.1\n"
+	want := []site{
+		{Name: "tokyo", IPs: []string{"10.1.0.10"}},
+		{Name: "osaka", IPs: []string{"10.2.0.10", "2001:db8::10"}},
+		{Name: "192.0.2.1", IPs: []string{"192.0.2.1"}},
+	}
+	got, err := readSites(strings.NewReader(input))
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("readSites() = %v, %v; want %v, nil", got, err, want)
+	}
+
+	_, err = readSites(strings.NewReader("tokyo 10.1.0.300\n"))
+	if err == nil {
+		t.Errorf("readSites(tokyo 10.1.0.300) = _, nil; want !nil")
+	}
+}
+
+func TestProcessMatrix(t *testing.T) {
+	pacFile := filepath.Join(t.TempDir(), "proxy.pac")
+	err := os.WriteFile(pacFile, []byte(`
+function FindProxyForURL(url, host) {
+    if (isPlainHostName(host)) {
+        return "DIRECT";
+    }
+    if (isInNet(myIpAddress(), "10.2.0.0", "255.255.0.0")) {
+        return "PROXY osaka-proxy:8080";
+    }
+    return "PROXY tokyo-proxy:8080";
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := config{
+		proxyPac: pacFile,
+		urls:     []string{"http://hoge.com/", "http://intra/"},
+		format:   "csv",
+	}
+	sites := []site{
+		{Name: "tokyo", IPs: []string{"10.1.0.10"}},
+		{Name: "osaka", IPs: []string{"10.2.0.10"}},
+	}
+	var buf bytes.Buffer
+	err = processMatrix(conf, sites, &buf)
+	want := "url,tokyo,osaka\n" +
+		"http://hoge.com/,PROXY tokyo-proxy:8080,PROXY osaka-proxy:8080\n" +
+		"http://intra/,DIRECT,DIRECT\n"
+	if err != nil || buf.String() != want {
+		t.Errorf("processMatrix() = %v\n%s\nwant nil\n%s", err, buf.String(), want)
+	}
+}
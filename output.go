@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// 出力形式
+var formats = []string{"text", "json", "jsonl", "csv", "tsv"}
+
+// record は1つのURLの評価結果
+type record struct {
+	URL        string      `json:"url"`
+	Host       string      `json:"host"`
+	Result     string      `json:"result"`
+	Proxies    []pac.Proxy `json:"proxies"`
+	DurationMS float64     `json:"duration_ms"`
+	Error      string      `json:"error,omitempty"`
+	Alerts     []string    `json:"alerts,omitempty"`
+	Trace      []traceCall `json:"trace,omitempty"`
+	Lookups    []dnsLookup `json:"dns_lookups,omitempty"`
+}
+
+// traceCall は評価中に呼び出された組み込み関数の記録
+type traceCall struct {
+	Call       string        `json:"call"`
+	Name       string        `json:"name"`
+	Args       []interface{} `json:"args"`
+	Result     interface{}   `json:"result"`
+	DurationMS float64       `json:"duration_ms"`
+}
+
+func newTraceCall(c pac.Call) (r traceCall) {
+	r = traceCall{
+		Call:       c.String(),
+		Name:       c.Name,
+		Args:       c.Args,
+		Result:     c.Result,
+		DurationMS: float64(c.Duration) / float64(time.Millisecond),
+	}
+	if r.Args == nil {
+		r.Args = []interface{}{}
+	}
+	return
+}
+
+// String は "isInNet(...) -> true (0.012ms)" の形式で記録を返す
+func (tc traceCall) String() string {
+	return fmt.Sprintf("%s (%.3fms)", tc.Call, tc.DurationMS)
+}
+
+func (rec *record) setDuration(d time.Duration) {
+	rec.DurationMS = float64(d) / float64(time.Millisecond)
+}
+
+// proxies は解析済みのエントリをFindProxyForURLの戻り値の書式で返す
+func (rec record) proxies() string {
+	a := make([]string, len(rec.Proxies))
+	for i, proxy := range rec.Proxies {
+		a[i] = proxy.String()
+	}
+	return strings.Join(a, "; ")
+}
+
+// recordWriter は評価結果を出力するインターフェース
+type recordWriter interface {
+	write(rec record) error
+	close() error
+}
+
+func newRecordWriter(format string, w io.Writer, parse bool) (r recordWriter, err error) {
+	switch format {
+	case "text":
+		r = &textWriter{w: w, parse: parse}
+	case "json":
+		r = &jsonWriter{w: w}
+	case "jsonl":
+		r = &jsonlWriter{enc: json.NewEncoder(w)}
+	case "csv":
+		r = newCSVWriter(w, ',')
+	case "tsv":
+		r = newCSVWriter(w, '\t')
+	default:
+		err = fmt.Errorf("unknown format: %s", format)
+	}
+	return
+}
+
+// textWriter は "url => result" の形式で出力する
+type textWriter struct {
+	w     io.Writer
+	parse bool
+}
+
+func (tw *textWriter) write(rec record) (err error) {
+	_, err = fmt.Fprintln(tw.w, rec.URL, "=>", rec.Result)
+	if err != nil {
+		return
+	}
+	for _, proxy := range rec.Proxies {
+		if !tw.parse {
+			break
+		}
+		if proxy.Type == pac.TypeDirect {
+			_, err = fmt.Fprintf(tw.w, "    %s\n", proxy.Type)
+		} else {
+			_, err = fmt.Fprintf(tw.w, "    %s host=%s port=%d\n", proxy.Type, proxy.Host, proxy.Port)
+		}
+		if err != nil {
+			return
+		}
+	}
+	// エラーと呼び出しや名前解決の記録は -parse を指定しなくても出力する
+	if rec.Error != "" {
+		_, err = fmt.Fprintf(tw.w, "    error: %s\n", rec.Error)
+		if err != nil {
+			return
+		}
+	}
+	for _, tc := range rec.Trace {
+		_, err = fmt.Fprintf(tw.w, "    trace: %s\n", tc)
+		if err != nil {
+			return
+		}
+	}
+	for _, l := range rec.Lookups {
+		_, err = fmt.Fprintf(tw.w, "    dns: %s\n", l)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (tw *textWriter) close() error {
+	return nil
+}
+
+// jsonWriter は評価結果の配列をJSONで出力する
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (jw *jsonWriter) write(rec record) (err error) {
+	var bb []byte
+	bb, err = json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	sep := ",\n"
+	if jw.count == 0 {
+		sep = "[\n"
+	}
+	jw.count++
+	_, err = fmt.Fprintf(jw.w, "%s  %s", sep, bb)
+	return
+}
+
+func (jw *jsonWriter) close() (err error) {
+	if jw.count == 0 {
+		_, err = fmt.Fprintln(jw.w, "[]")
+	} else {
+		_, err = fmt.Fprintln(jw.w, "\n]")
+	}
+	return
+}
+
+// jsonlWriter は評価結果を1行に1つのJSONで出力する
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (jw *jsonlWriter) write(rec record) error {
+	return jw.enc.Encode(rec)
+}
+
+func (jw *jsonlWriter) close() error {
+	return nil
+}
+
+// csvWriter は評価結果をCSVまたはTSVで出力する
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+var csvHeader = []string{"url", "host", "result", "proxies", "duration_ms", "error"}
+
+func newCSVWriter(w io.Writer, comma rune) (r *csvWriter) {
+	r = &csvWriter{w: csv.NewWriter(w)}
+	r.w.Comma = comma
+	return
+}
+
+func (cw *csvWriter) write(rec record) (err error) {
+	if !cw.header {
+		cw.header = true
+		err = cw.w.Write(csvHeader)
+		if err != nil {
+			return
+		}
+	}
+	err = cw.w.Write([]string{
+		rec.URL,
+		rec.Host,
+		rec.Result,
+		rec.proxies(),
+		strconv.FormatFloat(rec.DurationMS, 'f', 3, 64),
+		rec.Error,
+	})
+	return
+}
+
+func (cw *csvWriter) close() error {
+	if !cw.header {
+		cw.w.Write(csvHeader)
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+var testRecords = []record{
+	{
+		URL:    "http://hoge.com/hoge",
+		Host:   "hoge.com",
+		Result: "PROXY proxy2:8080; DIRECT",
+		Proxies: []pac.Proxy{
+			{Type: pac.TypeProxy, Host: "proxy2", Port: 8080},
+			{Type: pac.TypeDirect},
+		},
+		DurationMS: 0.5,
+	},
+	{
+		URL:     "http://hogehoge/",
+		Host:    "hogehoge",
+		Proxies: []pac.Proxy{},
+		Error:   "empty proxy list",
+	},
+}
+
+func TestRecordWriter(t *testing.T) {
+	pats := map[string]string{
+		"text": "http://hoge.com/hoge => PROXY proxy2:8080; DIRECT\n" +
+			"http://hogehoge/ => \n" +
+			"    error: empty proxy list\n",
+		"json": "[\n" +
+			`  {"url":"http://hoge.com/hoge","host":"hoge.com","result":"PROXY proxy2:8080; DIRECT","proxies":[{"type":"PROXY","host":"proxy2","port":8080},{"type":"DIRECT"}],"duration_ms":0.5},` + "\n" +
+			`  {"url":"http://hogehoge/","host":"hogehoge","result":"","proxies":[],"duration_ms":0,"error":"empty proxy list"}` + "\n" +
+			"]\n",
+		"jsonl": `{"url":"http://hoge.com/hoge","host":"hoge.com","result":"PROXY proxy2:8080; DIRECT","proxies":[{"type":"PROXY","host":"proxy2","port":8080},{"type":"DIRECT"}],"duration_ms":0.5}` + "\n" +
+			`{"url":"http://hogehoge/","host":"hogehoge","result":"","proxies":[],"duration_ms":0,"error":"empty proxy list"}` + "\n",
+		"csv": "url,host,result,proxies,duration_ms,error\n" +
+			"http://hoge.com/hoge,hoge.com,PROXY proxy2:8080; DIRECT,PROXY proxy2:8080; DIRECT,0.500,\n" +
+			"http://hogehoge/,hogehoge,,,0.000,empty proxy list\n",
+		"tsv": "url\thost\tresult\tproxies\tduration_ms\terror\n" +
+			"http://hoge.com/hoge\thoge.com\tPROXY proxy2:8080; DIRECT\tPROXY proxy2:8080; DIRECT\t0.500\t\n" +
+			"http://hogehoge/\thogehoge\t\t\t0.000\tempty proxy list\n",
+	}
+	for format, want := range pats {
+		var buf bytes.Buffer
+		w, err := newRecordWriter(format, &buf, false)
+		if err != nil {
+			t.Fatalf("newRecordWriter(%s) = _, %v; want nil", format, err)
+		}
+		for _, rec := range testRecords {
+			if err = w.write(rec); err != nil {
+				t.Fatalf("write() = %v; want nil", err)
+			}
+		}
+		if err = w.close(); err != nil {
+			t.Fatalf("close() = %v; want nil", err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("format %s:\n%s\nwant\n%s", format, got, want)
+		}
+	}
+}
+
+func TestTextWriterTrace(t *testing.T) {
+	rec := record{
+		URL:     "http://intra/",
+		Host:    "intra",
+		Result:  "DIRECT",
+		Proxies: []pac.Proxy{{Type: pac.TypeDirect}},
+		Trace: []traceCall{
+			newTraceCall(pac.Call{Name: "isInNet", Args: []interface{}{"10.1.2.3", "10.0.0.0", "255.0.0.0"}, Result: true}),
+		},
+	}
+	var buf bytes.Buffer
+	w, _ := newRecordWriter("text", &buf, false)
+	if err := w.write(rec); err != nil {
+		t.Fatalf("write() = %v; want nil", err)
+	}
+	want := "http://intra/ => DIRECT\n" +
+		`    trace: isInNet("10.1.2.3", "10.0.0.0", "255.0.0.0") -> true (0.000ms)` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("text with trace:\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package pac
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/robertkrimen/otto"
+)
+
+// defaultAlertLogger は alert() の既定の出力先
+var defaultAlertLogger = log.New(os.Stderr, "", 0)
+
+// WithAlertLogger は alert() のメッセージの出力先を指定する
+// 既定では標準エラー出力に "PAC-alert: メッセージ" の形式で出力する
+func WithAlertLogger(logger *log.Logger) Option {
+	return func(e *env) {
+		e.alertLogger = logger
+	}
+}
+
+// alertHandlerKey は alert() のメッセージを受け取る関数のコンテクストのキー
+type alertHandlerKey struct{}
+
+// WithAlertHandler は ctx で FindProxyForURL を呼び出している間の alert() のメッセージを
+// ロガーに出力する代わりに handler に渡すコンテクストを返す
+// URLごとにメッセージを集めるのに使用する
+func WithAlertHandler(ctx context.Context, handler func(msg string)) context.Context {
+	return context.WithValue(ctx, alertHandlerKey{}, handler)
+}
+
+/*
+alert(message)
+Parameters
+message
+the string to log
+Logs the message in the browser console.
+
+Example
+alert(host + " = " + dnsResolve(host));
+*/
+
+func (e *env) alert(call otto.FunctionCall) otto.Value {
+	msg := call.Argument(0).String()
+	if e.ctx != nil {
+		if handler, ok := e.ctx.Value(alertHandlerKey{}).(func(string)); ok {
+			handler(msg)
+			return otto.UndefinedValue()
+		}
+	}
+	if e.alertLogger != nil {
+		e.alertLogger.Println("PAC-alert: " + msg)
+	}
+	return otto.UndefinedValue()
+}
@@ -0,0 +1,44 @@
+package pac
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const alertScript = `
+alert("loaded");
+function FindProxyForURL(url, host) {
+    alert("host: " + host);
+    alert(dnsDomainLevels(host));
+    return "DIRECT";
+}
+`
+
+func TestAlert(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := Load(strings.NewReader(alertScript), WithAlertLogger(log.New(&buf, "", 0)))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	_, err = p.FindProxyForURL(context.Background(), "http://www.hoge.com/")
+	want := "PAC-alert: loaded\nPAC-alert: host: www.hoge.com\nPAC-alert: 2\n"
+	if err != nil || buf.String() != want {
+		t.Errorf("alert() logged %q, %v; want %q, nil", buf.String(), err, want)
+	}
+
+	// ハンドラを指定した場合はロガーに出力しない
+	buf.Reset()
+	var msgs []string
+	ctx := WithAlertHandler(context.Background(), func(msg string) {
+		msgs = append(msgs, msg)
+	})
+	_, err = p.FindProxyForURL(ctx, "http://hoge/")
+	if err != nil || !reflect.DeepEqual(msgs, []string{"host: hoge", "0"}) || buf.Len() != 0 {
+		t.Errorf("alert() handled %q and logged %q, %v; want [host: hoge 0], \"\", nil", msgs, buf.String(), err)
+	}
+}
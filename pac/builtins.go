@@ -0,0 +1,163 @@
+// builtins.go
+// 組み込み関数
+
+package pac
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// BuiltIns は組み込み関数を格納する変数
+var BuiltIns = map[string]interface{}{
+	"isPlainHostName":     isPlainHostName,
+	"dnsDomainIs":         dnsDomainIs,
+	"localHostOrDomainIs": localHostOrDomainIs,
+	"isResolvable":        defaultEnv.isResolvable,
+	"isInNet":             defaultEnv.isInNet,
+	"dnsResolve":          defaultEnv.dnsResolve,
+	"convertAddr":         convertAddr,
+	"myIpAddress":         defaultEnv.myIPAddress,
+	"myIPAddress":         defaultEnv.myIPAddress,
+	"dnsDomainLevels":     dnsDomainLevels,
+	"shExpMatch":          shExpMatch,
+	"weekdayRange":        defaultEnv.weekdayRange,
+	"dateRange":           defaultEnv.dateRange,
+	"timeRange":           defaultEnv.timeRange,
+	"alert":               defaultEnv.alert,
+
+	// IPv6 に対応した Microsoft の拡張関数
+	"isResolvableEx":    defaultEnv.isResolvableEx,
+	"dnsResolveEx":      defaultEnv.dnsResolveEx,
+	"myIpAddressEx":     defaultEnv.myIPAddressEx,
+	"isInNetEx":         defaultEnv.isInNetEx,
+	"sortIpAddressList": sortIPAddressList,
+	"getClientVersion":  getClientVersion,
+
+	// *ADD HERE*
+}
+
+func addBuiltIn(name string, value interface{}) {
+	BuiltIns[name] = value
+}
+
+// env は組み込み関数が参照する実行環境
+type env struct {
+	resolver Resolver
+	clock    Clock
+	timeout  time.Duration
+	fallback string
+	local    *localAddrs
+
+	alertLogger *log.Logger
+	trace       bool
+	dnsProfile  bool
+	coverage    *Coverage
+
+	// vm は組み込み関数を登録した実行コンテクスト (名前解決を呼び出した行の取得に使用する)
+	vm *otto.Otto
+
+	// ctx は評価中の呼び出しのコンテクスト (DNSの名前解決、alert() と呼び出しの記録に使用する)
+	ctx context.Context
+}
+
+// Option はPACの実行環境を設定する関数
+type Option func(*env)
+
+// WithResolver はDNSの名前解決に使用するリゾルバを指定する
+func WithResolver(resolver Resolver) Option {
+	return func(e *env) {
+		e.resolver = resolver
+	}
+}
+
+// defaultEnv はオプションを指定しない場合の実行環境
+var defaultEnv = newEnv()
+
+func newEnv(opts ...Option) (e *env) {
+	e = &env{
+		resolver: net.DefaultResolver,
+		clock:    systemClock{},
+		local:    &localAddrs{},
+
+		alertLogger: defaultAlertLogger,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return
+}
+
+// builtIns は実行環境に依存する組み込み関数を返す
+func (e *env) builtIns() map[string]interface{} {
+	return map[string]interface{}{
+		"isResolvable": e.isResolvable,
+		"isInNet":      e.isInNet,
+		"dnsResolve":   e.dnsResolve,
+		"myIpAddress":  e.myIPAddress,
+		"myIPAddress":  e.myIPAddress,
+		"weekdayRange": e.weekdayRange,
+		"dateRange":    e.dateRange,
+		"timeRange":    e.timeRange,
+		"alert":        e.alert,
+
+		"isResolvableEx": e.isResolvableEx,
+		"dnsResolveEx":   e.dnsResolveEx,
+		"myIpAddressEx":  e.myIPAddressEx,
+		"isInNetEx":      e.isInNetEx,
+	}
+}
+
+// setBuiltIns は実行環境に依存する組み込み関数を vm に登録する
+// 呼び出しを記録する場合は全ての組み込み関数をラップして登録し直す
+func (e *env) setBuiltIns(vm *otto.Otto) (err error) {
+	e.vm = vm
+	builtIns := e.builtIns()
+	if e.trace {
+		for name, value := range BuiltIns {
+			if _, ok := builtIns[name]; !ok {
+				builtIns[name] = value
+			}
+		}
+		for name, value := range builtIns {
+			builtIns[name] = e.traced(name, value)
+		}
+	}
+
+	for name, value := range builtIns {
+		err = vm.Set(name, value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// lookupHost は組み込み関数 fn のためにリゾルバでホスト名を解決する
+// IPアドレスはリゾルバに問い合わせずにそのまま返す
+func (e *env) lookupHost(fn, host string) (addrs []string, err error) {
+	if net.ParseIP(host) != nil {
+		addrs = []string{host}
+		return
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	addrs, err = e.lookupResolver().LookupHost(ctx, host)
+	if e.dnsProfile {
+		e.profileLookup(Lookup{
+			Func:     fn,
+			Host:     host,
+			Addrs:    addrs,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+	return
+}
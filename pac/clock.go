@@ -0,0 +1,33 @@
+package pac
+
+import (
+	"time"
+)
+
+// Clock は weekdayRange, dateRange, timeRange が参照する現在時刻を返すインターフェース
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock はシステムの現在時刻を返す時計
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock は常に同じ時刻を返す時計
+// 任意の時刻でPACスクリプトを評価するのに使用する
+type FixedClock time.Time
+
+// Now は固定された時刻を返す
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// WithClock は時刻に関する組み込み関数が参照する時計を指定する
+func WithClock(clock Clock) Option {
+	return func(e *env) {
+		e.clock = clock
+	}
+}
@@ -0,0 +1,38 @@
+package pac
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithClock(t *testing.T) {
+	script := `
+function FindProxyForURL(url, host) {
+    if (dateRange(24, "DEC") && timeRange(17, 23)) {
+        return "PROXY xmas:8080";
+    }
+    if (weekdayRange("SAT", "SUN")) {
+        return "PROXY weekend:8080";
+    }
+    return "DIRECT";
+}
+`
+	jst := time.FixedZone("JST", 9*60*60)
+	pats := map[time.Time]string{
+		time.Date(2026, 12, 24, 18, 0, 0, 0, jst): "PROXY xmas:8080",
+		time.Date(2026, 12, 24, 9, 0, 0, 0, jst):  "DIRECT",
+		time.Date(2026, 12, 26, 18, 0, 0, 0, jst): "PROXY weekend:8080",
+	}
+	for now, want := range pats {
+		p, err := Load(strings.NewReader(script), WithClock(FixedClock(now)))
+		if err != nil {
+			t.Fatalf("Load() = _, %v; want nil", err)
+		}
+		got, err := p.FindProxyForURL(context.Background(), "http://www.foo.co.jp/")
+		if err != nil || got != want {
+			t.Errorf("FindProxyForURL() at %v = %v, %v; want %v, nil", now, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package pac
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/robertkrimen/otto"
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/parser"
+)
+
+// coverageFunc は実行回数を数えるためにスクリプトに埋め込む関数の名前
+const coverageFunc = "__findproxy_cov"
+
+// Coverage はPACスクリプトの行、if 文の分岐と関数ごとの実行回数
+// 同じ Coverage を指定してコンパイルしたスクリプトの実行回数は合算される
+type Coverage struct {
+	mu           sync.Mutex
+	name         string
+	src          []byte
+	instrumented []byte
+
+	counts   []int64
+	stmts    []covStmt
+	funcs    []covFunc
+	branches []covBranch
+}
+
+// covStmt は文の行とカウンタ
+type covStmt struct {
+	line, counter int
+}
+
+// covFunc は関数の名前、行と呼び出し回数のカウンタ
+type covFunc struct {
+	name          string
+	line, counter int
+}
+
+// covBranch は if 文の行、条件を評価した回数と真になった回数のカウンタ
+type covBranch struct {
+	line, test, taken int
+}
+
+// WithCoverage はPACスクリプトの実行回数を cov に集計する
+// スクリプトには行番号が変わらないようにカウンタの呼び出しが埋め込まれる
+// (エラーメッセージの桁は埋め込んだ後のスクリプトのものになる)
+func WithCoverage(cov *Coverage) Option {
+	return func(e *env) {
+		e.coverage = cov
+	}
+}
+
+// instrument はカウンタの呼び出しを埋め込んだスクリプトを返す
+// 別のスクリプトの実行回数を集計している場合はエラーを返す
+func (c *Coverage) instrument(name string, src []byte) (r []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.src != nil {
+		if !bytes.Equal(c.src, src) {
+			err = fmt.Errorf("%s: coverage is already collected for %s", name, c.name)
+			return
+		}
+		r = c.instrumented
+		return
+	}
+
+	var program *ast.Program
+	program, err = parser.ParseFile(nil, name, src, 0)
+	if err != nil {
+		return
+	}
+	in := &instrumenter{
+		cov:   c,
+		src:   src,
+		tests: map[*ast.IfStatement]int{},
+	}
+	for i, b := range src {
+		if b == '\n' {
+			in.lineStarts = append(in.lineStarts, i+1)
+		}
+	}
+	ast.Walk(in, program)
+
+	sort.SliceStable(in.inserts, func(i, j int) bool {
+		return in.inserts[i].offset < in.inserts[j].offset
+	})
+	var buf bytes.Buffer
+	last := 0
+	for _, ins := range in.inserts {
+		buf.Write(src[last:ins.offset])
+		buf.WriteString(ins.text)
+		last = ins.offset
+	}
+	buf.Write(src[last:])
+
+	c.name = name
+	c.src = src
+	c.instrumented = buf.Bytes()
+	c.counts = make([]int64, in.counters)
+	r = c.instrumented
+	return
+}
+
+// count はスクリプトに埋め込んだカウンタの呼び出しを処理する
+func (c *Coverage) count(call otto.FunctionCall) otto.Value {
+	n, err := call.Argument(0).ToInteger()
+	if err == nil && n >= 0 && n < int64(len(c.counts)) {
+		atomic.AddInt64(&c.counts[n], 1)
+	}
+	return otto.UndefinedValue()
+}
+
+func (c *Coverage) load(counter int) int64 {
+	return atomic.LoadInt64(&c.counts[counter])
+}
+
+// instrumenter はスクリプトの構文木をたどってカウンタを埋め込む位置を決める
+type instrumenter struct {
+	cov        *Coverage
+	src        []byte
+	lineStarts []int
+	counters   int
+	inserts    []insertion
+	anonymous  int
+
+	// tests は if 文の条件に埋め込んだカウンタ
+	tests map[*ast.IfStatement]int
+}
+
+// insertion はスクリプトに埋め込む文字列と位置
+// 改行を含まないため行番号は変わらない
+type insertion struct {
+	offset int
+	text   string
+}
+
+func (in *instrumenter) line(offset int) int {
+	return sort.SearchInts(in.lineStarts, offset+1) + 1
+}
+
+func (in *instrumenter) insert(offset int, format string, counter int) {
+	in.inserts = append(in.inserts, insertion{offset, fmt.Sprintf(format, coverageFunc, counter)})
+}
+
+func (in *instrumenter) newCounter() (r int) {
+	r = in.counters
+	in.counters++
+	return
+}
+
+func (in *instrumenter) Enter(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.Program:
+		in.list(n.Body)
+	case *ast.BlockStatement:
+		if n != nil {
+			in.list(n.List)
+		}
+	case *ast.CaseStatement:
+		in.list(n.Consequent)
+	case *ast.FunctionLiteral:
+		body, ok := n.Body.(*ast.BlockStatement)
+		if !ok {
+			break
+		}
+		name := ""
+		if n.Name != nil {
+			name = n.Name.Name
+		} else {
+			in.anonymous++
+			name = "(anonymous_" + strconv.Itoa(in.anonymous) + ")"
+		}
+		counter := in.newCounter()
+		in.insert(int(body.LeftBrace), "%s(%d);", counter)
+		in.cov.funcs = append(in.cov.funcs, covFunc{name, in.line(int(n.Function) - 1), counter})
+	case *ast.IfStatement:
+		taken := in.body(n.Consequent)
+		if n.Alternate != nil {
+			in.body(n.Alternate)
+		}
+		// ラベルの付いた if 文には条件のカウンタがない
+		if test, ok := in.tests[n]; ok {
+			in.cov.branches = append(in.cov.branches, covBranch{in.line(int(n.If) - 1), test, taken})
+		}
+	case *ast.ForStatement:
+		in.body(n.Body)
+	case *ast.ForInStatement:
+		in.body(n.Body)
+	case *ast.WhileStatement:
+		in.body(n.Body)
+	case *ast.DoWhileStatement:
+		in.body(n.Body)
+	case *ast.WithStatement:
+		in.body(n.Body)
+	}
+	return in
+}
+
+func (in *instrumenter) Exit(n ast.Node) {}
+
+// list は文の並びのそれぞれの文の前にカウンタを埋め込む
+func (in *instrumenter) list(stmts []ast.Statement) {
+	for _, s := range stmts {
+		switch s.(type) {
+		case *ast.FunctionStatement, *ast.EmptyStatement:
+			// 宣言と空文は実行されない
+		default:
+			in.statement(s, false)
+		}
+	}
+}
+
+// body は if 文やループの本体にカウンタを埋め込み、本体を実行した回数のカウンタを返す
+func (in *instrumenter) body(s ast.Statement) (counter int) {
+	if block, ok := s.(*ast.BlockStatement); ok {
+		counter = in.newCounter()
+		in.insert(int(block.LeftBrace), "%s(%d);", counter)
+		return
+	}
+	counter = in.statement(s, true)
+	return
+}
+
+// statement は文の前にカウンタを埋め込む
+// ブロックで囲まれていない本体では else と結び付かないように if 文で埋め込む
+// if 文では条件の括弧の直後に埋め込み、else if の連鎖を崩さないようにする
+func (in *instrumenter) statement(s ast.Statement, body bool) (counter int) {
+	counter = in.newCounter()
+	offset := in.start(s)
+	line := in.line(offset)
+	switch s := s.(type) {
+	case *ast.IfStatement:
+		in.tests[s] = counter
+		line = in.line(int(s.If) - 1)
+		in.insert(in.testParen(s), "%s(%d),", counter)
+	default:
+		if body {
+			in.insert(offset, "if(%s(%d),0);else ", counter)
+		} else {
+			in.insert(offset, "%s(%d);", counter)
+		}
+	}
+	in.cov.stmts = append(in.cov.stmts, covStmt{line, counter})
+	return
+}
+
+// start は文の先頭の位置を返す
+// 式文の位置は括弧の内側を指すため、直前の開き括弧を含める
+// ループや throw 文などの構文木の先頭の位置は正しくないため、最初の子の前のキーワードを探す
+func (in *instrumenter) start(s ast.Statement) (r int) {
+	switch s := s.(type) {
+	case *ast.WhileStatement:
+		r = in.keyword("while", s.Test)
+	case *ast.DoWhileStatement:
+		r = in.keyword("do", s.Body)
+	case *ast.ForStatement:
+		for _, child := range []ast.Node{s.Initializer, s.Test, s.Update, s.Body} {
+			if child != nil && in.start0(child) >= 0 {
+				r = in.keyword("for", child)
+				return
+			}
+		}
+	case *ast.ForInStatement:
+		r = in.keyword("for", s.Into)
+	case *ast.WithStatement:
+		r = in.keyword("with", s.Object)
+	case *ast.SwitchStatement:
+		r = in.keyword("switch", s.Discriminant)
+	case *ast.ThrowStatement:
+		r = in.keyword("throw", s.Argument)
+	case *ast.ExpressionStatement:
+		r = exprStart(s.Expression)
+		for i := r - 1; i >= 0 && strings.IndexByte(" \t\r\n(", in.src[i]) >= 0; i-- {
+			if in.src[i] == '(' {
+				r = i
+			}
+		}
+	default:
+		r = int(s.Idx0()) - 1
+	}
+	return
+}
+
+// testParen は if 文の条件を囲む開き括弧の直後の位置を返す
+// 条件の先頭の位置は条件の中の括弧の内側を指すことがあり、構文木の if の位置も正しくないため、
+// 条件の前のキーワード if の後の括弧を探す
+func (in *instrumenter) testParen(s *ast.IfStatement) int {
+	i := in.keyword("if", s.Test) + len("if")
+	return i + bytes.IndexByte(in.src[i:], '(') + 1
+}
+
+// keyword は child の前にある最後のキーワード kw の位置を返す
+func (in *instrumenter) keyword(kw string, child ast.Node) (r int) {
+	r = in.start0(child)
+	for i := r; i > 0; {
+		i = bytes.LastIndex(in.src[:i], []byte(kw))
+		if i < 0 {
+			break
+		}
+		if (i == 0 || !isIdentByte(in.src[i-1])) && !isIdentByte(in.src[i+len(kw)]) {
+			r = i
+			break
+		}
+	}
+	return
+}
+
+// start0 は子の構文木の先頭の位置を返す
+func (in *instrumenter) start0(n ast.Node) int {
+	switch n := n.(type) {
+	case ast.Statement:
+		return in.start(n)
+	case ast.Expression:
+		return exprStart(n)
+	}
+	return int(n.Idx0()) - 1
+}
+
+// exprStart は式の先頭の位置を返す (空の式は -1)
+// 後置の ++ と -- の位置は演算子を指すため、最も左の子をたどる
+func exprStart(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.UnaryExpression:
+		if e.Postfix {
+			return exprStart(e.Operand)
+		}
+	case *ast.AssignExpression:
+		return exprStart(e.Left)
+	case *ast.BinaryExpression:
+		return exprStart(e.Left)
+	case *ast.BracketExpression:
+		return exprStart(e.Left)
+	case *ast.CallExpression:
+		return exprStart(e.Callee)
+	case *ast.ConditionalExpression:
+		return exprStart(e.Test)
+	case *ast.DotExpression:
+		return exprStart(e.Left)
+	case *ast.SequenceExpression:
+		if len(e.Sequence) == 0 {
+			return -1
+		}
+		return exprStart(e.Sequence[0])
+	}
+	return int(e.Idx0()) - 1
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '$' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// CoverageSummary は実行された行、分岐と関数の数
+type CoverageSummary struct {
+	Lines, LinesHit         int
+	Branches, BranchesHit   int
+	Functions, FunctionsHit int
+}
+
+// String は "lines 12/20 (60.0%), branches 3/8 (37.5%), functions 2/3 (66.7%)" の形式で返す
+func (s CoverageSummary) String() string {
+	return fmt.Sprintf("lines %s, branches %s, functions %s",
+		ratio(s.LinesHit, s.Lines), ratio(s.BranchesHit, s.Branches), ratio(s.FunctionsHit, s.Functions))
+}
+
+func ratio(hit, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%)", hit, total, float64(hit)*100/float64(total))
+}
+
+// lineCounts は行ごとの実行回数を返す
+// 1行に複数の文がある場合は最も多く実行された文の回数とする
+func (c *Coverage) lineCounts() (r map[int]int64) {
+	r = map[int]int64{}
+	for _, s := range c.stmts {
+		if n := c.load(s.counter); n >= r[s.line] {
+			r[s.line] = n
+		}
+	}
+	for _, f := range c.funcs {
+		if n := c.load(f.counter); n >= r[f.line] {
+			r[f.line] = n
+		}
+	}
+	return
+}
+
+// branchCounts は if 文の条件が真になった回数と偽になった回数を返す
+func (c *Coverage) branchCounts(b covBranch) (taken, notTaken int64) {
+	taken = c.load(b.taken)
+	notTaken = c.load(b.test) - taken
+	return
+}
+
+// Summary は実行された行、分岐と関数の数を返す
+func (c *Coverage) Summary() (s CoverageSummary) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, n := range c.lineCounts() {
+		s.Lines++
+		if n > 0 {
+			s.LinesHit++
+		}
+	}
+	for _, b := range c.branches {
+		taken, notTaken := c.branchCounts(b)
+		s.Branches += 2
+		if taken > 0 {
+			s.BranchesHit++
+		}
+		if notTaken > 0 {
+			s.BranchesHit++
+		}
+	}
+	for _, f := range c.funcs {
+		s.Functions++
+		if c.load(f.counter) > 0 {
+			s.FunctionsHit++
+		}
+	}
+	return
+}
+
+// WriteLCOV は実行回数をlcovのトレースファイルの形式で出力する
+func (c *Coverage) WriteLCOV(w io.Writer) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "TN:\nSF:%s\n", c.name)
+
+	hit := 0
+	for _, f := range c.funcs {
+		fmt.Fprintf(&b, "FN:%d,%s\n", f.line, f.name)
+	}
+	for _, f := range c.funcs {
+		n := c.load(f.counter)
+		if n > 0 {
+			hit++
+		}
+		fmt.Fprintf(&b, "FNDA:%d,%s\n", n, f.name)
+	}
+	fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", len(c.funcs), hit)
+
+	hit = 0
+	for i, br := range c.branches {
+		taken, notTaken := c.branchCounts(br)
+		for j, n := range []int64{taken, notTaken} {
+			s := "-"
+			if c.load(br.test) > 0 {
+				s = strconv.FormatInt(n, 10)
+			}
+			if n > 0 {
+				hit++
+			}
+			fmt.Fprintf(&b, "BRDA:%d,%d,%d,%s\n", br.line, i, j, s)
+		}
+	}
+	fmt.Fprintf(&b, "BRF:%d\nBRH:%d\n", len(c.branches)*2, hit)
+
+	counts := c.lineCounts()
+	lines := make([]int, 0, len(counts))
+	for line := range counts {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	hit = 0
+	for _, line := range lines {
+		if counts[line] > 0 {
+			hit++
+		}
+		fmt.Fprintf(&b, "DA:%d,%d\n", line, counts[line])
+	}
+	fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", len(lines), hit)
+
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+// WriteAnnotated は各行の先頭に実行回数を付けたソースを出力する
+// 実行される文のない行は "-"、一度も実行されなかった行は "#####" を付け、
+// if 文の行の後には条件が真と偽になった回数を付ける
+func (c *Coverage) WriteAnnotated(w io.Writer) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := c.lineCounts()
+	branches := map[int][]covBranch{}
+	for _, br := range c.branches {
+		branches[br.line] = append(branches[br.line], br)
+	}
+
+	var b strings.Builder
+	lines := strings.Split(strings.TrimSuffix(string(c.src), "\n"), "\n")
+	for i, text := range lines {
+		line := i + 1
+		mark := "-"
+		if n, ok := counts[line]; ok {
+			mark = formatCount(n)
+		}
+		fmt.Fprintf(&b, "%9s:%5d:%s\n", mark, line, strings.TrimSuffix(text, "\r"))
+		for _, br := range branches[line] {
+			taken, notTaken := c.branchCounts(br)
+			fmt.Fprintf(&b, "%9s %5s branch: true %s, false %s\n", "", "", formatCount(taken), formatCount(notTaken))
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return
+}
+
+func formatCount(n int64) string {
+	if n == 0 {
+		return "#####"
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,146 @@
+package pac
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const coverageScript = `// 社内向けのPAC (コメントの行は数えない)
+function isIntra(host) {
+    return dnsDomainIs(host, ".intra");
+}
+
+function FindProxyForURL(url, host) {
+    if (isPlainHostName(host)) return "DIRECT";
+    else if (isIntra(host)) {
+        return "PROXY intra:8080";
+    } else if (shExpMatch(host, "*.dead")) {
+        return "PROXY dead:8080";
+    }
+    var i = 0;
+    while (i < 2) i++;
+    (function () { i++; })();
+    return "PROXY proxy:8080";
+}
+`
+
+// TestCoverageSameResult はカウンタを埋め込んでも結果が変わらないことを確認する
+func TestCoverageSameResult(t *testing.T) {
+	const script = `var o = { f: function () { return true; } };
+var n = 0;
+function FindProxyForURL(url, host) {
+    if ((o.f)() && host == "e") return "PROXY e:8080";
+    if ((n)++ >= 0) return "PROXY " + n;
+    return "DIRECT";
+}
+`
+	for _, host := range []string{"e", "f"} {
+		var results []string
+		for _, opts := range [][]Option{nil, {WithCoverage(&Coverage{})}} {
+			p, err := Compile("proxy.pac", []byte(script), opts...)
+			if err != nil {
+				t.Fatalf("Compile() = _, %v; want nil", err)
+			}
+			r, err := p.FindProxyForURLHost(context.Background(), "http://"+host+"/", host)
+			if err != nil {
+				t.Fatalf("FindProxyForURL(%s) = _, %v; want nil", host, err)
+			}
+			results = append(results, r)
+		}
+		if results[0] != results[1] {
+			t.Errorf("FindProxyForURL(%s) = %q with coverage; want %q", host, results[1], results[0])
+		}
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	cov := &Coverage{}
+	p, err := Compile("proxy.pac", []byte(coverageScript), WithCoverage(cov))
+	if err != nil {
+		t.Fatalf("Compile() = _, %v; want nil", err)
+	}
+	if string(p.Source()) != coverageScript {
+		t.Errorf("Source() = %q; want the original script", p.Source())
+	}
+
+	urls := map[string]string{
+		"http://hoge/":          "DIRECT",
+		"http://www.intra/":     "PROXY intra:8080",
+		"http://www.intra/a":    "PROXY intra:8080",
+		"http://www.example/":   "PROXY proxy:8080",
+		"http://www.example/b":  "PROXY proxy:8080",
+		"http://www.example/cd": "PROXY proxy:8080",
+	}
+	for u, want := range urls {
+		r, err := p.FindProxyForURL(context.Background(), u)
+		if r != want || err != nil {
+			t.Errorf("FindProxyForURL(%s) = %v, %v; want %v, nil", u, r, err, want)
+		}
+	}
+
+	// 同じ Coverage でコンパイルし直しても合算される
+	p, err = Compile("proxy.pac", []byte(coverageScript), WithCoverage(cov))
+	if err != nil {
+		t.Fatalf("Compile() = _, %v; want nil", err)
+	}
+	p.FindProxyForURL(context.Background(), "http://fuga/")
+
+	var buf bytes.Buffer
+	err = cov.WriteAnnotated(&buf)
+	if err != nil {
+		t.Fatalf("WriteAnnotated() = %v; want nil", err)
+	}
+	want := `        -:    1:// 社内向けのPAC (コメントの行は数えない)
+        5:    2:function isIntra(host) {
+        5:    3:    return dnsDomainIs(host, ".intra");
+        -:    4:}
+        -:    5:
+        7:    6:function FindProxyForURL(url, host) {
+        7:    7:    if (isPlainHostName(host)) return "DIRECT";
+                branch: true 2, false 5
+        5:    8:    else if (isIntra(host)) {
+                branch: true 2, false 3
+        2:    9:        return "PROXY intra:8080";
+        3:   10:    } else if (shExpMatch(host, "*.dead")) {
+                branch: true #####, false 3
+    #####:   11:        return "PROXY dead:8080";
+        -:   12:    }
+        3:   13:    var i = 0;
+        6:   14:    while (i < 2) i++;
+        3:   15:    (function () { i++; })();
+        3:   16:    return "PROXY proxy:8080";
+        -:   17:}
+`
+	if buf.String() != want {
+		t.Errorf("WriteAnnotated() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	s := cov.Summary()
+	if s.String() != "lines 11/12 (91.7%), branches 5/6 (83.3%), functions 3/3 (100.0%)" {
+		t.Errorf("Summary() = %v", s)
+	}
+
+	buf.Reset()
+	err = cov.WriteLCOV(&buf)
+	if err != nil {
+		t.Fatalf("WriteLCOV() = %v; want nil", err)
+	}
+	for _, line := range []string{
+		"SF:proxy.pac\n",
+		"FN:2,isIntra\n", "FNDA:7,FindProxyForURL\n", "FNDA:3,(anonymous_1)\n", "FNF:3\nFNH:3\n",
+		"BRDA:10,2,0,0\nBRDA:10,2,1,3\n", "BRF:6\nBRH:5\n",
+		"DA:11,0\n", "DA:14,6\n", "LF:12\nLH:11\nend_of_record\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("WriteLCOV() = %s; want %q", buf.String(), line)
+		}
+	}
+
+	// 別のスクリプトには使えない
+	_, err = Compile("other.pac", []byte(`function FindProxyForURL(url, host) { return "DIRECT"; }`), WithCoverage(cov))
+	if err == nil {
+		t.Errorf("Compile(other.pac) = _, nil; want error")
+	}
+}
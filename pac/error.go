@@ -0,0 +1,52 @@
+package pac
+
+import (
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// ScriptError はPACスクリプトの実行中に発生したエラー
+type ScriptError struct {
+	Name    string   // PACスクリプトのファイル名
+	Message string   // エラーメッセージ (例: "ReferenceError: 'foo' is not defined")
+	Stack   []string // JavaScriptのスタックトレース (例: "FindProxyForURL (proxy.pac:3:5)")
+}
+
+func (e *ScriptError) Error() string {
+	if len(e.Stack) < 1 {
+		return e.Name + ": " + e.Message
+	}
+	return e.Message + " at " + e.Stack[0]
+}
+
+// scriptError は otto のエラーをスタックトレース付きの *ScriptError に変換する
+func scriptError(name string, err error) error {
+	oe, ok := err.(*otto.Error)
+	if !ok {
+		return err
+	}
+
+	r := &ScriptError{
+		Name:    name,
+		Message: oe.Error(),
+	}
+	// String() は "メッセージ\n    at 関数 (ファイル:行:桁)\n..." を返す
+	for _, line := range strings.Split(oe.String(), "\n")[1:] {
+		frame := strings.TrimPrefix(strings.TrimSpace(line), "at ")
+		if frame == "" || frame == "<unknown>" {
+			continue
+		}
+		r.Stack = append(r.Stack, frame)
+	}
+	return r
+}
+
+// WithFallback はPACスクリプトの評価に失敗したときに FindProxyForURL が返す結果を指定する
+// ブラウザと同じく "DIRECT" を指定すると直接接続に切り替えられる
+// エラーは結果と一緒に返される
+func WithFallback(result string) Option {
+	return func(e *env) {
+		e.fallback = result
+	}
+}
@@ -0,0 +1,58 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// panicResolver は名前解決で panic を起こすリゾルバ
+type panicResolver struct{}
+
+func (panicResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	panic("resolver is broken")
+}
+
+const errorScript = `
+function FindProxyForURL(url, host) {
+    if (host == "undefined") {
+        return;
+    }
+    if (host == "resolve") {
+        return dnsResolve(host);
+    }
+    return foo(host);
+}
+`
+
+func TestScriptError(t *testing.T) {
+	p, err := Compile("error.pac", []byte(errorScript), WithResolver(panicResolver{}))
+	if err != nil {
+		t.Fatalf("Compile() = _, %v; want nil", err)
+	}
+
+	pats := map[string]string{
+		"http://hoge/":      "ReferenceError: 'foo' is not defined at FindProxyForURL (error.pac:9:12)",
+		"http://undefined/": "error.pac: FindProxyForURL returned undefined, not a string",
+		"http://resolve/":   "error.pac: panic: resolver is broken",
+	}
+	for rawurl, want := range pats {
+		r, err := p.FindProxyForURL(context.Background(), rawurl)
+		var se *ScriptError
+		if r != "" || !errors.As(err, &se) || err.Error() != want {
+			t.Errorf("FindProxyForURL(%s) = %q, %v; want \"\", %s", rawurl, r, err, want)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	p, err := Load(strings.NewReader(errorScript), WithFallback("DIRECT"))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+	r, err := p.FindProxyForURL(context.Background(), "http://hoge/")
+	if r != "DIRECT" || err == nil {
+		t.Errorf("FindProxyForURL(http://hoge/) = %q, %v; want DIRECT, !nil", r, err)
+	}
+}
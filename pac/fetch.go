@@ -0,0 +1,300 @@
+package pac
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MIMEType はPACファイルのMIMEタイプ
+const MIMEType = "application/x-ns-proxy-autoconfig"
+
+// acceptableTypes はPACファイルとして受け付けるMIMEタイプ
+var acceptableTypes = []string{
+	MIMEType,
+	"application/x-javascript-config",
+	"application/javascript",
+	"application/x-javascript",
+	"text/javascript",
+	"text/plain",
+	"application/octet-stream",
+}
+
+// maxPACSize は取得するPACファイルの最大サイズ
+const maxPACSize = 16 * 1024 * 1024
+
+// Fetcher はHTTP(S)でPACファイルを取得する
+type Fetcher struct {
+	// Client はリクエストに使用するクライアント
+	// nil の場合は http.DefaultClient を使用する
+	Client *http.Client
+
+	// CacheDir は取得したPACファイルをキャッシュするディレクトリ
+	// 空の場合はキャッシュせず、条件付きリクエストも行わない
+	CacheDir string
+
+	// StrictContentType が true の場合は Content-Type が
+	// application/x-ns-proxy-autoconfig の応答だけを受け付ける
+	StrictContentType bool
+}
+
+// NewFetcher はタイムアウト、リダイレクトの最大回数、追加のCA証明書を指定して Fetcher を生成する
+// net/http の既定と同じく maxRedirects 回目のリダイレクトで取得を中止する
+// caFile が空の場合はシステムのCA証明書だけを使用する
+func NewFetcher(timeout time.Duration, maxRedirects int, caFile string) (r *Fetcher, err error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if caFile != "" {
+		var pem []byte
+		pem, err = os.ReadFile(caFile)
+		if err != nil {
+			return
+		}
+		pool, e := x509.SystemCertPool()
+		if e != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			err = fmt.Errorf("%s: no certificate", caFile)
+			return
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	r = &Fetcher{
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return nil
+			},
+		},
+	}
+	return
+}
+
+// IsURL は PACファイルの場所がHTTP(S)のURLかどうかを返す
+func IsURL(location string) (r bool) {
+	s := strings.ToLower(location)
+	r = strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+	return
+}
+
+// Load はURLから取得したPACスクリプトで新規JavaSript実行コンテクストを生成する
+func (f *Fetcher) Load(ctx context.Context, rawurl string, opts ...Option) (p *PAC, err error) {
+	var src []byte
+	src, err = f.Fetch(ctx, rawurl)
+	if err != nil {
+		return
+	}
+	p, err = Compile(rawurl, src, opts...)
+	return
+}
+
+// cacheEntry はキャッシュしたPACファイルの検証用の情報
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	SHA256       string `json:"sha256"` // 対になるPACファイルのハッシュ
+}
+
+// Fetch はURLからPACファイルを取得する
+// キャッシュがある場合は条件付きリクエストを行い、変更がなければキャッシュを返す
+func (f *Fetcher) Fetch(ctx context.Context, rawurl string) (src []byte, err error) {
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", MIMEType+", */*;q=0.8")
+
+	cached, entry := f.readCache(rawurl)
+	if cached != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var resp *http.Response
+	resp, err = client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		src = cached
+		return
+	case resp.StatusCode != http.StatusOK:
+		err = fmt.Errorf("%s: %s", rawurl, resp.Status)
+		return
+	}
+
+	err = f.checkContentType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		err = fmt.Errorf("%s: %v", rawurl, err)
+		return
+	}
+
+	// 途中で切れたスクリプトを実行しないよう、最大サイズを超える場合はエラーにする
+	src, err = io.ReadAll(io.LimitReader(resp.Body, maxPACSize+1))
+	if err != nil {
+		return
+	}
+	if len(src) > maxPACSize {
+		src = nil
+		err = fmt.Errorf("%s: larger than %d bytes", rawurl, maxPACSize)
+		return
+	}
+
+	// キャッシュは最適化のためのものなので、書き込めなくても取得は成功とする
+	// 書き込みに失敗した場合は古い検証用の情報が残らないようキャッシュを消す
+	e := f.writeCache(rawurl, src, cacheEntry{
+		URL:          rawurl,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+	if e != nil {
+		f.removeCache(rawurl)
+	}
+	return
+}
+
+func (f *Fetcher) checkContentType(contentType string) (err error) {
+	if contentType == "" {
+		return
+	}
+	mediaType, _, e := mime.ParseMediaType(contentType)
+	if e != nil {
+		err = fmt.Errorf("abnormal Content-Type: %s", contentType)
+		return
+	}
+
+	if f.StrictContentType {
+		if mediaType != MIMEType {
+			err = fmt.Errorf("unexpected Content-Type: %s", mediaType)
+		}
+		return
+	}
+
+	for _, t := range acceptableTypes {
+		if mediaType == t {
+			return
+		}
+	}
+	err = fmt.Errorf("unexpected Content-Type: %s", mediaType)
+	return
+}
+
+// cachePath はURLに対応するキャッシュファイルのパスを返す
+func (f *Fetcher) cachePath(rawurl string) string {
+	return filepath.Join(f.CacheDir, sha256Hex([]byte(rawurl)))
+}
+
+// readCache はキャッシュしたPACファイルを読み込む
+// キャッシュがないか、検証用の情報と対になっていない場合は nil を返す
+func (f *Fetcher) readCache(rawurl string) (src []byte, entry cacheEntry) {
+	if f.CacheDir == "" {
+		return
+	}
+	path := f.cachePath(rawurl)
+	bb, err := os.ReadFile(path + ".json")
+	if err != nil || json.Unmarshal(bb, &entry) != nil || entry.URL != rawurl {
+		return
+	}
+	src, err = os.ReadFile(path + ".pac")
+	if err != nil || sha256Hex(src) != entry.SHA256 {
+		src = nil
+	}
+	return
+}
+
+// writeCache はPACファイルをキャッシュする
+// 途中で中断されたり並行に書き込まれたりしても壊れたファイルが残らないよう、
+// それぞれ一時ファイルに書いてから置き換え、検証用の情報を最後に書く
+func (f *Fetcher) writeCache(rawurl string, src []byte, entry cacheEntry) (err error) {
+	if f.CacheDir == "" {
+		return
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		// 条件付きリクエストに使えないため、以前のキャッシュも消す
+		f.removeCache(rawurl)
+		return
+	}
+	err = os.MkdirAll(f.CacheDir, 0700)
+	if err != nil {
+		return
+	}
+	entry.SHA256 = sha256Hex(src)
+	var bb []byte
+	bb, err = json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	path := f.cachePath(rawurl)
+	err = writeFileAtomic(path+".pac", src)
+	if err != nil {
+		return
+	}
+	err = writeFileAtomic(path+".json", bb)
+	return
+}
+
+// removeCache はURLのキャッシュを消す
+func (f *Fetcher) removeCache(rawurl string) {
+	path := f.cachePath(rawurl)
+	os.Remove(path + ".json")
+	os.Remove(path + ".pac")
+}
+
+// writeFileAtomic は同じディレクトリの一時ファイルに書き込んでからファイルを置き換える
+func writeFileAtomic(filePath string, data []byte) (err error) {
+	var tmp *os.File
+	tmp, err = os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	_, err = tmp.Write(data)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), filePath)
+	return
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
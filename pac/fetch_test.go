@@ -0,0 +1,101 @@
+package pac
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetcher(t *testing.T) {
+	notModified := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proxy.pac", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", MIMEType)
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testScript))
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html></html>"))
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(testScript))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MIMEType)
+		w.Write(bytes.Repeat([]byte("\n"), maxPACSize+1))
+	})
+	mux.Handle("/wpad.dat", http.RedirectHandler("/proxy.pac", http.StatusFound))
+	mux.Handle("/r2", http.RedirectHandler("/wpad.dat", http.StatusFound))
+	mux.Handle("/r3", http.RedirectHandler("/r2", http.StatusFound))
+	mux.Handle("/loop", http.RedirectHandler("/loop", http.StatusFound))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	f, err := NewFetcher(5*time.Second, 3, "")
+	if err != nil {
+		t.Fatalf("NewFetcher() = _, %v; want nil", err)
+	}
+	f.CacheDir = t.TempDir()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		p, err := f.Load(ctx, ts.URL+"/proxy.pac")
+		if err != nil {
+			t.Fatalf("Load() = _, %v; want nil", err)
+		}
+		got, _ := p.FindProxyForURL(ctx, "http://hoge.com/hoge")
+		if got != "PROXY proxy2:8080" {
+			t.Errorf("FindProxyForURL() = %v; want PROXY proxy2:8080", got)
+		}
+	}
+	if notModified != 1 {
+		t.Errorf("304 responses = %d; want 1", notModified)
+	}
+
+	// 検証用の情報と対になっていないPACファイルは使わずに取得し直す
+	path := f.cachePath(ts.URL + "/proxy.pac")
+	if err = os.WriteFile(path+".pac", []byte("function FindProxyForURL("), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := f.Fetch(ctx, ts.URL+"/proxy.pac")
+	if err != nil || string(src) != testScript || notModified != 1 {
+		t.Errorf("Fetch() with a mismatched cache = %q, %v after %d 304 responses; want the script, nil after 1", src, err, notModified)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(f.CacheDir, "*.tmp")); len(tmps) > 0 {
+		t.Errorf("temporary files left: %v", tmps)
+	}
+
+	// リダイレクトは2回まで従い、3回目で中止する
+	if _, err = f.Fetch(ctx, ts.URL+"/wpad.dat"); err != nil {
+		t.Errorf("Fetch(/wpad.dat) = _, %v; want nil", err)
+	}
+	if _, err = f.Fetch(ctx, ts.URL+"/r2"); err != nil {
+		t.Errorf("Fetch(/r2) = _, %v; want nil", err)
+	}
+	if _, err = f.Fetch(ctx, ts.URL+"/plain"); err != nil {
+		t.Errorf("Fetch(/plain) = _, %v; want nil", err)
+	}
+
+	for _, path := range []string{"/html", "/loop", "/r3", "/large", "/notfound"} {
+		if _, err = f.Fetch(ctx, ts.URL+path); err == nil {
+			t.Errorf("Fetch(%s) = _, nil; want !nil", path)
+		}
+	}
+
+	f.StrictContentType = true
+	if _, err = f.Fetch(ctx, ts.URL+"/plain"); err == nil {
+		t.Errorf("Fetch(/plain) with StrictContentType = _, nil; want !nil")
+	}
+}
@@ -0,0 +1,212 @@
+// Package pac はプロキシ自動設定 (PAC) ファイルを評価するライブラリ
+package pac
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/robertkrimen/otto"
+)
+
+// defaultName は名前のないPACスクリプトに付けるファイル名
+const defaultName = "proxy.pac"
+
+// PAC コンパイル済みのPACスクリプトとJavaScript実行コンテクスト
+// 複数のゴルーチンから並行に評価できる
+type PAC struct {
+	name   string
+	src    []byte
+	script *otto.Script
+
+	// vm はスクリプトを実行し終えた直後の実行コンテクストで、複製の元として使う
+	// otto.Otto は並行に使えないため、評価には pool から取り出した複製を使う
+	mu   sync.Mutex
+	vm   *otto.Otto
+	env  *env
+	pool sync.Pool
+}
+
+// Load は r から読み込んだPACスクリプトで新規JavaSript実行コンテクストを生成する
+func Load(r io.Reader, opts ...Option) (p *PAC, err error) {
+	var src []byte
+	src, err = io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	p, err = Compile(defaultName, src, opts...)
+	return
+}
+
+// LoadFile はファイルから読み込んだPACスクリプトで新規JavaSript実行コンテクストを生成する
+func LoadFile(filePath string, opts ...Option) (p *PAC, err error) {
+	var src []byte
+	src, err = os.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	p, err = Compile(filePath, src, opts...)
+	return
+}
+
+// Compile はPACスクリプトのソースで新規JavaSript実行コンテクストを生成する
+// fileName はエラーメッセージなどに使用される
+func Compile(fileName string, src []byte, opts ...Option) (r *PAC, err error) {
+	vm := otto.New()
+	var script *otto.Script
+	script, err = vm.Compile(fileName, src)
+	if err != nil {
+		return
+	}
+
+	// 実行回数を集計する場合はカウンタを埋め込んだスクリプトに置き換える
+	e := newEnv(opts...)
+	if e.coverage != nil {
+		var instrumented []byte
+		instrumented, err = e.coverage.instrument(fileName, src)
+		if err != nil {
+			return
+		}
+		script, err = vm.Compile(fileName, instrumented)
+		if err != nil {
+			return
+		}
+		err = vm.Set(coverageFunc, e.coverage.count)
+		if err != nil {
+			return
+		}
+	}
+
+	// 組み込み関数をJavaSript実行コンテクストに登録
+	for name, value := range BuiltIns {
+		err = vm.Set(name, value)
+		if err != nil {
+			return
+		}
+	}
+
+	// 実行環境に依存する組み込み関数で上書き
+	err = e.setBuiltIns(vm)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := e.withTimeout(context.Background())
+	defer cancel()
+	e.ctx = ctx
+	_, err = runContext(ctx, fileName, vm, func() (otto.Value, error) {
+		return vm.Run(script)
+	})
+	e.ctx = nil
+	if err != nil {
+		return
+	}
+
+	r = &PAC{
+		name:   fileName,
+		src:    src,
+		vm:     vm,
+		env:    e,
+		script: script,
+	}
+	return
+}
+
+// instance は評価に使用する実行コンテクストの複製とその実行環境
+type instance struct {
+	vm  *otto.Otto
+	env *env
+}
+
+// getInstance は pool から評価に使用する実行コンテクストを取り出す
+// pool が空の場合は新たに複製する
+func (p *PAC) getInstance() (in *instance, err error) {
+	if v := p.pool.Get(); v != nil {
+		in = v.(*instance)
+		return
+	}
+	in, err = p.newInstance()
+	return
+}
+
+// newInstance はスクリプトを実行し終えた直後の実行コンテクストを複製する
+// 評価ごとのコンテクストを参照できるよう、実行環境も複製して組み込み関数を登録し直す
+func (p *PAC) newInstance() (in *instance, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := *p.env
+	vm := p.vm.Copy()
+	err = e.setBuiltIns(vm)
+	if err != nil {
+		return
+	}
+	in = &instance{
+		vm:  vm,
+		env: &e,
+	}
+	return
+}
+
+// Name はPACスクリプトのファイル名を返す
+func (p *PAC) Name() string {
+	return p.name
+}
+
+// Source はPACスクリプトのソースを返す (WithCoverage を指定した場合もカウンタを埋め込む前のもの)
+func (p *PAC) Source() []byte {
+	return p.src
+}
+
+// FindProxyForURL は与えられたURLへの接続に使用すべきプロキシを返す関数
+// ホスト名はURLから取り出される
+func (p *PAC) FindProxyForURL(ctx context.Context, rawurl string) (r string, err error) {
+	var u *url.URL
+	u, err = url.Parse(rawurl)
+	if err != nil {
+		return
+	}
+	r, err = p.FindProxyForURLHost(ctx, rawurl, u.Hostname())
+	return
+}
+
+// FindProxyForURLHost は与えられたURLとホスト名への接続に使用すべきプロキシを返す関数
+// スクリプトのエラーや文字列以外の戻り値は *ScriptError、制限時間を超えた場合は *TimeoutError を返す
+// WithFallback を指定した場合はエラーと一緒にその結果を返す
+func (p *PAC) FindProxyForURLHost(ctx context.Context, rawurl, host string) (r string, err error) {
+	ctx, cancel := p.env.withTimeout(ctx)
+	defer cancel()
+
+	var in *instance
+	in, err = p.getInstance()
+	if err == nil {
+		r, err = p.call(ctx, in, rawurl, host)
+	}
+
+	if err != nil && p.env.fallback != "" {
+		r = p.env.fallback
+	}
+	return
+}
+
+// call は実行コンテクスト in で FindProxyForURL を呼び出す
+func (p *PAC) call(ctx context.Context, in *instance, rawurl, host string) (r string, err error) {
+	in.env.ctx = ctx
+	var value otto.Value
+	value, err = runContext(ctx, p.name, in.vm, func() (otto.Value, error) {
+		return in.vm.Call("FindProxyForURL", nil, rawurl, host)
+	})
+	in.env.ctx = nil
+	// エラーになった実行コンテクストは状態が不定のため再利用しない
+	if err == nil {
+		p.pool.Put(in)
+		if value.IsString() {
+			r = value.String()
+		} else {
+			err = &ScriptError{Name: p.name, Message: "FindProxyForURL returned " + value.String() + ", not a string"}
+		}
+	}
+	return
+}
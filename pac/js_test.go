@@ -0,0 +1,71 @@
+package pac
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testScript = `
+function FindProxyForURL(url, host) {
+    if (dnsDomainIs(host, ".foo.co.jp")) {
+        return "PROXY proxy1:8000";
+    }
+    if (shExpMatch(host, "*.com")) {
+        return "PROXY proxy2:8080";
+    }
+    return "DIRECT";
+}
+`
+
+func TestFindProxyForURL(t *testing.T) {
+	p, err := Load(strings.NewReader(testScript))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	pats := map[string]string{
+		"http://www.foo.co.jp/":    "PROXY proxy1:8000",
+		"http://hoge.com/hoge":     "PROXY proxy2:8080",
+		"https://hoge.com:8443/":   "PROXY proxy2:8080",
+		"http://hogehoge/hoge":     "DIRECT",
+		"http://www.foo.co.jp.com": "PROXY proxy2:8080",
+	}
+	for rawurl, want := range pats {
+		got, err := p.FindProxyForURL(context.Background(), rawurl)
+		if err != nil || got != want {
+			t.Errorf("FindProxyForURL(%s) = %v, %v; want %v, nil", rawurl, got, err, want)
+		}
+	}
+}
+
+func TestFindProxyForURLParallel(t *testing.T) {
+	p, err := Load(strings.NewReader(testScript))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				got, err := p.FindProxyForURL(context.Background(), "http://www.foo.co.jp/")
+				if err != nil || got != "PROXY proxy1:8000" {
+					t.Errorf("FindProxyForURL(http://www.foo.co.jp/) = %v, %v; want PROXY proxy1:8000, nil", got, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoadSyntaxError(t *testing.T) {
+	_, err := Load(strings.NewReader("function FindProxyForURL(url, host) {"))
+	if err == nil {
+		t.Errorf("Load() = _, nil; want !nil")
+	}
+}
@@ -0,0 +1,487 @@
+package pac
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/robertkrimen/otto"
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/file"
+	"github.com/robertkrimen/otto/parser"
+)
+
+// Severity は Lint で見つかった問題の重大度
+type Severity int
+
+const (
+	// SeverityWarning は動作するが意図どおりでないおそれがある問題
+	SeverityWarning Severity = iota
+	// SeverityError はエラーになるか、明らかに意図どおりに動作しない問題
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue は Lint で見つかった問題
+type Issue struct {
+	Line     int // 行 (1から)
+	Column   int // 桁 (1から、バイト単位)
+	Severity Severity
+	Message  string
+}
+
+// String は "3:5: warning: メッセージ" の形式で問題を返す
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", i.Line, i.Column, i.Severity, i.Message)
+}
+
+// builtInArity は組み込み関数の引数の最小と最大の数
+var builtInArity = map[string][2]int{
+	"isPlainHostName":     {1, 1},
+	"dnsDomainIs":         {2, 2},
+	"localHostOrDomainIs": {2, 2},
+	"isResolvable":        {1, 1},
+	"isInNet":             {3, 3},
+	"dnsResolve":          {1, 1},
+	"convertAddr":         {1, 1},
+	"myIpAddress":         {0, 0},
+	"myIPAddress":         {0, 0},
+	"dnsDomainLevels":     {1, 1},
+	"shExpMatch":          {2, 2},
+	"weekdayRange":        {1, 3},
+	"dateRange":           {1, 7},
+	"timeRange":           {1, 7},
+	"alert":               {1, 1},
+	"isResolvableEx":      {1, 1},
+	"dnsResolveEx":        {1, 1},
+	"myIpAddressEx":       {0, 0},
+	"isInNetEx":           {2, 2},
+	"sortIpAddressList":   {1, 1},
+	"getClientVersion":    {0, 0},
+}
+
+// dnsBuiltIns はホスト名を解決する組み込み関数
+var dnsBuiltIns = map[string]bool{
+	"isResolvable":   true,
+	"isInNet":        true,
+	"dnsResolve":     true,
+	"isResolvableEx": true,
+	"dnsResolveEx":   true,
+	"isInNetEx":      true,
+}
+
+// cheapBuiltIns は名前を解決せずにホスト名を調べる組み込み関数
+var cheapBuiltIns = map[string]bool{
+	"isPlainHostName":     true,
+	"dnsDomainIs":         true,
+	"localHostOrDomainIs": true,
+	"dnsDomainLevels":     true,
+	"shExpMatch":          true,
+}
+
+var (
+	globalsOnce sync.Once
+	globals     map[string]bool
+)
+
+// isGlobal は name がJavaScriptの組み込みのオブジェクトや関数かどうかを返す
+// 名前の一覧は最初に呼び出したときに作り、それ以降は読み出すだけなので並行に呼び出せる
+func isGlobal(name string) bool {
+	globalsOnce.Do(func() {
+		globals = map[string]bool{}
+		v, err := otto.New().Run(`Object.getOwnPropertyNames(this)`)
+		if err != nil {
+			return
+		}
+		names, _ := v.Export()
+		list, _ := names.([]string)
+		for _, n := range list {
+			globals[n] = true
+		}
+	})
+	return globals[name]
+}
+
+// Lint はPACスクリプトを実行せずに調べ、よくある誤りを行と桁の順に返す
+// 次の問題を報告する
+//   - 構文エラー
+//   - FindProxyForURL が定義されていないか、引数が (url, host) の2つでない
+//   - 定義されていない関数の呼び出しと、組み込み関数の引数の数の誤り
+//   - dnsDomainIs のドメインが "." で始まっていない
+//   - 一致することのない shExpMatch のパターン
+//   - FindProxyForURL が返す文字列リテラルの書式の誤り
+//   - 名前を解決せずに済む host の検査より前にある名前解決
+func Lint(name string, src []byte) (issues []Issue) {
+	l := &linter{
+		src:      src,
+		declared: map[string]bool{},
+	}
+	program, err := parser.ParseFile(nil, name, src, 0)
+	if err != nil {
+		if list, ok := err.(parser.ErrorList); ok {
+			for _, e := range list {
+				issues = append(issues, Issue{e.Position.Line, e.Position.Column, SeverityError, e.Message})
+			}
+			return
+		}
+		issues = append(issues, Issue{1, 1, SeverityError, err.Error()})
+		return
+	}
+
+	ast.Walk(declarations{l}, program)
+	l.findProxyForURL(program)
+	ast.Walk(l, program)
+	if l.main != nil {
+		l.returns(l.main.Body)
+		l.dnsOrder(l.main.Body)
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	issues = l.issues
+	return
+}
+
+// linter はスクリプトの構文木をたどって問題を集める
+type linter struct {
+	src      []byte
+	issues   []Issue
+	declared map[string]bool
+
+	// main は FindProxyForURL の定義、host はその2番目の引数の名前
+	main *ast.FunctionLiteral
+	host string
+}
+
+func (l *linter) report(idx file.Idx, severity Severity, format string, a ...interface{}) {
+	line, column := l.position(idx)
+	l.issues = append(l.issues, Issue{line, column, severity, fmt.Sprintf(format, a...)})
+}
+
+// position は構文木の位置を行と桁に変換する
+func (l *linter) position(idx file.Idx) (line, column int) {
+	offset := int(idx) - 1
+	if offset < 0 || offset > len(l.src) {
+		offset = 0
+	}
+	src := l.src[:offset]
+	line = bytes.Count(src, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(src, '\n')
+	return
+}
+
+// declarations はスクリプトで定義された関数と変数の名前を集める
+type declarations struct {
+	l *linter
+}
+
+func (d declarations) Enter(n ast.Node) ast.Visitor {
+	switch n := n.(type) {
+	case *ast.FunctionLiteral:
+		if n.Name != nil {
+			d.l.declared[n.Name.Name] = true
+		}
+		for _, p := range n.ParameterList.List {
+			d.l.declared[p.Name] = true
+		}
+	case *ast.VariableExpression:
+		d.l.declared[n.Name] = true
+	case *ast.CatchStatement:
+		if n != nil {
+			d.l.declared[n.Parameter.Name] = true
+		}
+	case *ast.AssignExpression:
+		if id, ok := n.Left.(*ast.Identifier); ok {
+			d.l.declared[id.Name] = true
+		}
+	}
+	return d
+}
+
+func (d declarations) Exit(n ast.Node) {}
+
+// findProxyForURL は FindProxyForURL の定義を探し、引数の数を調べる
+func (l *linter) findProxyForURL(program *ast.Program) {
+	for _, s := range program.Body {
+		switch s := s.(type) {
+		case *ast.FunctionStatement:
+			if s.Function.Name != nil && s.Function.Name.Name == "FindProxyForURL" {
+				l.main = s.Function
+			}
+		case *ast.VariableStatement:
+			for _, e := range s.List {
+				if v, ok := e.(*ast.VariableExpression); ok && v.Name == "FindProxyForURL" {
+					l.main, _ = v.Initializer.(*ast.FunctionLiteral)
+				}
+			}
+		case *ast.ExpressionStatement:
+			if a, ok := s.Expression.(*ast.AssignExpression); ok {
+				if id, ok := a.Left.(*ast.Identifier); ok && id.Name == "FindProxyForURL" {
+					l.main, _ = a.Right.(*ast.FunctionLiteral)
+				}
+			}
+		}
+	}
+
+	if l.main == nil {
+		l.report(1, SeverityError, "FindProxyForURL is not defined")
+		return
+	}
+	params := l.main.ParameterList.List
+	if len(params) != 2 {
+		l.report(l.main.Idx0(), SeverityWarning, "FindProxyForURL has %d parameters; want 2 (url, host)", len(params))
+	}
+	if len(params) >= 2 {
+		l.host = params[1].Name
+	}
+}
+
+func (l *linter) Enter(n ast.Node) ast.Visitor {
+	if call, ok := n.(*ast.CallExpression); ok {
+		l.call(call)
+	}
+	return l
+}
+
+func (l *linter) Exit(n ast.Node) {}
+
+// call は関数の呼び出しを調べる
+func (l *linter) call(call *ast.CallExpression) {
+	callee, ok := call.Callee.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	name := callee.Name
+	if l.declared[name] {
+		return
+	}
+	if _, ok := BuiltIns[name]; !ok {
+		if !isGlobal(name) {
+			l.report(callee.Idx, SeverityError, "call to undefined function %s%s", name, l.suggest(name))
+		}
+		return
+	}
+
+	args := call.ArgumentList
+	if arity, ok := builtInArity[name]; ok {
+		takes := "takes"
+		if arity[0] != arity[1] {
+			takes = "takes at least"
+		}
+		if len(args) < arity[0] {
+			l.report(callee.Idx, SeverityError, "%s %s %s, got %d", name, takes, plural(arity[0], "argument"), len(args))
+		} else if len(args) > arity[1] {
+			if arity[0] != arity[1] {
+				takes = "takes at most"
+			}
+			l.report(callee.Idx, SeverityWarning, "%s %s %s, got %d", name, takes, plural(arity[1], "argument"), len(args))
+		}
+	}
+
+	switch name {
+	case "dnsDomainIs":
+		if len(args) < 2 {
+			break
+		}
+		if domain, ok := args[1].(*ast.StringLiteral); ok && domain.Value != "" && !strings.HasPrefix(domain.Value, ".") {
+			l.report(domain.Idx, SeverityWarning, "dnsDomainIs domain %q has no leading dot; it also matches hosts like \"x%s\" (use %q)",
+				domain.Value, domain.Value, "."+domain.Value)
+		}
+	case "shExpMatch":
+		if len(args) < 2 {
+			break
+		}
+		if pattern, ok := args[1].(*ast.StringLiteral); ok {
+			l.shExp(args[0], pattern)
+		}
+	}
+}
+
+// suggest は大文字と小文字だけが異なる組み込み関数や関数があればその名前を返す
+func (l *linter) suggest(name string) string {
+	var candidates []string
+	for builtIn := range BuiltIns {
+		candidates = append(candidates, builtIn)
+	}
+	for declared := range l.declared {
+		candidates = append(candidates, declared)
+	}
+	sort.Strings(candidates)
+	for _, c := range candidates {
+		if strings.EqualFold(c, name) {
+			return " (did you mean " + c + "?)"
+		}
+	}
+	return ""
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// shExp は shExpMatch のパターンが一致しうるかを調べる
+func (l *linter) shExp(str ast.Expression, pattern *ast.StringLiteral) {
+	p := pattern.Value
+	expr := strings.ReplaceAll(p, ".", "\\.")
+	expr = strings.ReplaceAll(expr, "*", ".*")
+	expr = strings.ReplaceAll(expr, "?", ".")
+	if _, err := regexp.Compile(expr); err != nil {
+		l.report(pattern.Idx, SeverityError, "shExpMatch pattern %q never matches: it is not a valid expression", p)
+		return
+	}
+	if strings.IndexFunc(p, unicode.IsSpace) >= 0 {
+		l.report(pattern.Idx, SeverityWarning, "shExpMatch pattern %q never matches: URLs and host names contain no spaces", p)
+		return
+	}
+
+	id, ok := str.(*ast.Identifier)
+	if !ok || l.host == "" || id.Name != l.host {
+		return
+	}
+	if strings.Contains(p, "/") {
+		l.report(pattern.Idx, SeverityWarning, "shExpMatch pattern %q never matches %s: host names contain no \"/\" (match the url instead)", p, id.Name)
+	} else if strings.ToLower(p) != p {
+		l.report(pattern.Idx, SeverityWarning, "shExpMatch pattern %q never matches %s: browsers pass host names in lower case", p, id.Name)
+	}
+}
+
+// returns は FindProxyForURL が返すリテラルを調べる (入れ子の関数は除く)
+func (l *linter) returns(body ast.Statement) {
+	ast.Walk(visitFunc(func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.ReturnStatement:
+			l.result(n)
+		}
+		return true
+	}), body)
+}
+
+// result は return 文の値が文字列リテラルならプロキシの指定として解析する
+func (l *linter) result(r *ast.ReturnStatement) {
+	switch arg := r.Argument.(type) {
+	case nil:
+		l.report(r.Return, SeverityError, "FindProxyForURL returns undefined, not a string")
+	case *ast.StringLiteral:
+		proxies, err := ParseProxies(arg.Value)
+		if err == nil {
+			break
+		}
+		severity := SeverityError
+		if len(proxies) > 0 {
+			// ブラウザは解析できないエントリを読み飛ばす
+			severity = SeverityWarning
+		}
+		l.report(arg.Idx, severity, "malformed result %q: %v", arg.Value, err)
+	case *ast.NumberLiteral:
+		l.report(arg.Idx, SeverityError, "FindProxyForURL returns %s, not a string", arg.Literal)
+	case *ast.BooleanLiteral:
+		l.report(arg.Idx, SeverityError, "FindProxyForURL returns %s, not a string", arg.Literal)
+	case *ast.NullLiteral:
+		l.report(arg.Idx, SeverityError, "FindProxyForURL returns null, not a string")
+	}
+}
+
+// dnsOrder は FindProxyForURL の文の並びで、名前を解決する呼び出しより後に
+// 名前を解決しない host の検査だけの文があれば報告する
+// ブラウザは名前の解決が終わるまで待たされるため、安価な検査を先に行うべきである
+func (l *linter) dnsOrder(body ast.Statement) {
+	block, ok := body.(*ast.BlockStatement)
+	if !ok {
+		return
+	}
+	var first *ast.CallExpression
+	for _, s := range block.List {
+		dns, cheap := l.calls(s)
+		if first == nil {
+			first = dns
+			continue
+		}
+		if dns == nil && cheap != nil {
+			name := first.Callee.(*ast.Identifier).Name
+			line, _ := l.position(cheap.Idx0())
+			l.report(first.Idx0(), SeverityWarning, "%s resolves the host on every request before the checks at line %d that need no DNS lookup; move them first", name, line)
+			return
+		}
+	}
+}
+
+// calls は文の中で最初に名前を解決する組み込み関数と名前を解決しない組み込み関数の呼び出しを返す
+func (l *linter) calls(s ast.Statement) (dns, cheap *ast.CallExpression) {
+	ast.Walk(visitFunc(func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.CallExpression:
+			id, ok := n.Callee.(*ast.Identifier)
+			if !ok || l.declared[id.Name] {
+				break
+			}
+			if dns == nil && dnsBuiltIns[id.Name] && l.resolves(n) {
+				dns = n
+			}
+			if cheap == nil && cheapBuiltIns[id.Name] {
+				cheap = n
+			}
+		}
+		return true
+	}), s)
+	return
+}
+
+// addrBuiltIns はIPアドレスを返す組み込み関数
+// これらの戻り値を渡した呼び出しは名前を解決しない (dnsResolve 自身の名前解決は別に数える)
+var addrBuiltIns = map[string]bool{
+	"myIpAddress":   true,
+	"myIPAddress":   true,
+	"myIpAddressEx": true,
+	"dnsResolve":    true,
+	"dnsResolveEx":  true,
+}
+
+// resolves は呼び出しが名前を解決するかを返す
+// IPアドレスのリテラルや myIpAddress() などの戻り値を渡した場合は名前を解決しない
+func (l *linter) resolves(call *ast.CallExpression) bool {
+	if len(call.ArgumentList) < 1 {
+		return false
+	}
+	switch arg := call.ArgumentList[0].(type) {
+	case *ast.StringLiteral:
+		return net.ParseIP(arg.Value) == nil
+	case *ast.CallExpression:
+		if id, ok := arg.Callee.(*ast.Identifier); ok && addrBuiltIns[id.Name] && !l.declared[id.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// visitFunc は関数を ast.Visitor として使う
+// 関数が false を返した場合は子をたどらない
+type visitFunc func(n ast.Node) bool
+
+func (f visitFunc) Enter(n ast.Node) ast.Visitor {
+	if !f(n) {
+		return nil
+	}
+	return f
+}
+
+func (f visitFunc) Exit(n ast.Node) {}
@@ -0,0 +1,111 @@
+package pac
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	src := `function isIntra(h) {
+    return dnsDomainIs(h, "foo.co.jp");
+}
+
+function FindProxyForURL(url, host) {
+    var ip = dnsResolve(host);
+    if (isPlainHostName(host) || isIntra(host)) {
+        return "DIRECT";
+    }
+    if (isInNet(ip, "10.0.0.0")) {
+        return "PROXY intra";
+    }
+    if (shExpMatch(host, "*.Example.com") || shExpMatch(host, "*/ari/*") || shExpMatch(url, "*[*")) {
+        return "PROXY proxy:8080; FOO bar";
+    }
+    if (isInNetEX(host, "2001:db8::/32")) return;
+    Math.max(1, parseInt("2"));
+    return "PROXY proxy:8080";
+}
+`
+	got := []string{}
+	for _, i := range Lint("proxy.pac", []byte(src)) {
+		got = append(got, i.String())
+	}
+	want := []string{
+		`2:27: warning: dnsDomainIs domain "foo.co.jp" has no leading dot; it also matches hosts like "xfoo.co.jp" (use ".foo.co.jp")`,
+		`6:14: warning: dnsResolve resolves the host on every request before the checks at line 7 that need no DNS lookup; move them first`,
+		`10:9: error: isInNet takes 3 arguments, got 2`,
+		`11:16: error: malformed result "PROXY intra": entry 1 "PROXY intra": missing port: intra`,
+		`13:26: warning: shExpMatch pattern "*.Example.com" never matches host: browsers pass host names in lower case`,
+		`13:63: warning: shExpMatch pattern "*/ari/*" never matches host: host names contain no "/" (match the url instead)`,
+		`13:93: error: shExpMatch pattern "*[*" never matches: it is not a valid expression`,
+		`14:16: warning: malformed result "PROXY proxy:8080; FOO bar": entry 2 "FOO bar": unknown keyword: FOO`,
+		`16:9: error: call to undefined function isInNetEX (did you mean isInNetEx?)`,
+		`16:43: error: FindProxyForURL returns undefined, not a string`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() =\n%q\nwant\n%q", got, want)
+	}
+
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`function FindProxyForURL(url, host) { return "DIRECT"; }`, []string{}},
+		{`var FindProxyForURL = function (url) { return "DIRECT"; };`, []string{
+			`1:23: warning: FindProxyForURL has 1 parameters; want 2 (url, host)`,
+		}},
+		{`function findProxyForURL(url, host) { return "DIRECT"; }`, []string{
+			`1:1: error: FindProxyForURL is not defined`,
+		}},
+		{`function FindProxyForURL(url, host) { return "DIRECT" `, []string{
+			`1:55: error: Unexpected end of input`,
+		}},
+		// 名前を解決しない検査が先にあれば報告しない
+		{`function FindProxyForURL(url, host) {
+    if (dnsDomainIs(host, ".foo.co.jp")) return "DIRECT";
+    if (isInNet(host, "10.0.0.0", "255.0.0.0") || isInNet("10.1.2.3", "10.0.0.0", "255.0.0.0")) return "DIRECT";
+    return "PROXY proxy:8080";
+}`, []string{}},
+		// myIpAddress() やIPアドレスを渡した isInNet は名前を解決しない
+		{`function FindProxyForURL(url, host) {
+    if (isInNet(myIpAddress(), "10.0.0.0", "255.0.0.0")) return "PROXY intra:8080";
+    if (isInNetEx(myIpAddressEx(), "2001:db8::/32") || isInNet("10.1.2.3", "10.0.0.0", "255.0.0.0")) return "DIRECT";
+    if (isPlainHostName(host)) return "DIRECT";
+    return "PROXY proxy:8080";
+}`, []string{}},
+		// dnsResolve の結果を渡した場合は dnsResolve が名前を解決する
+		{`function FindProxyForURL(url, host) {
+    if (isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0")) return "PROXY intra:8080";
+    if (isPlainHostName(host)) return "DIRECT";
+    return "PROXY proxy:8080";
+}`, []string{
+			`2:17: warning: dnsResolve resolves the host on every request before the checks at line 3 that need no DNS lookup; move them first`,
+		}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, i := range Lint("proxy.pac", []byte(tt.src)) {
+			got = append(got, i.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lint(%q) = %q; want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestLintConcurrent(t *testing.T) {
+	src := []byte(`function FindProxyForURL(url, host) { return Math.max(1, parseInt("2")) ? "DIRECT" : undefinedFunc(); }`)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			issues := Lint("proxy.pac", src)
+			if len(issues) != 1 || issues[0].Message != "call to undefined function undefinedFunc" {
+				t.Errorf("Lint() = %v; want 1 undefined function", issues)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,53 @@
+package pac
+
+import (
+	"context"
+	"time"
+)
+
+// Lookup は評価中に組み込み関数が行ったDNSの名前解決の記録
+// ブラウザは名前解決が終わるまでリクエストを待たせるため、PACスクリプトの性能の調査に使う
+type Lookup struct {
+	Func     string        // 名前を解決した組み込み関数 (例: "isInNet")
+	Host     string        // 解決したホスト名
+	Addrs    []string      // 解決したアドレス
+	Err      error         // 名前解決のエラー
+	Duration time.Duration // 名前解決を待った時間
+	Caller   string        // 組み込み関数を呼び出したJavaScriptの関数 (例: "FindProxyForURL")
+	Line     int           // 組み込み関数を呼び出したPACスクリプトの行 (不明な場合は 0)
+}
+
+// WithDNSProfile は組み込み関数によるDNSの名前解決を記録できるようにする
+// IPアドレスを渡した場合は名前解決を行わないため記録しない
+// 記録は WithLookupHandler で指定した関数に渡される
+func WithDNSProfile() Option {
+	return func(e *env) {
+		e.dnsProfile = true
+	}
+}
+
+// lookupHandlerKey は名前解決の記録を受け取る関数のコンテクストのキー
+type lookupHandlerKey struct{}
+
+// WithLookupHandler は ctx で FindProxyForURL を呼び出している間の名前解決を
+// handler に渡すコンテクストを返す (WithDNSProfile を指定したPACスクリプトのみ)
+func WithLookupHandler(ctx context.Context, handler func(l Lookup)) context.Context {
+	return context.WithValue(ctx, lookupHandlerKey{}, handler)
+}
+
+// profileLookup は名前解決を呼び出し元の関数と行とともにハンドラに渡す
+func (e *env) profileLookup(l Lookup) {
+	if e.ctx == nil {
+		return
+	}
+	handler, _ := e.ctx.Value(lookupHandlerKey{}).(func(Lookup))
+	if handler == nil {
+		return
+	}
+	if e.vm != nil {
+		// 組み込み関数のフレームを除いた、呼び出し元のスクリプトの位置
+		c := e.vm.ContextSkip(0, true)
+		l.Caller, l.Line = c.Callee, c.Line
+	}
+	handler(l)
+}
@@ -0,0 +1,53 @@
+package pac
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDNSProfile(t *testing.T) {
+	p, err := Load(strings.NewReader(`function isIntra(host) {
+    return isInNet(host, "10.0.0.0", "255.0.0.0");
+}
+function FindProxyForURL(url, host) {
+    if (isIntra(host)) return "DIRECT";
+    if (isResolvable(host) && dnsResolve("10.1.2.3")) return "PROXY proxy:8080";
+    return "DIRECT";
+}
+`), WithDNSProfile(), WithResolver(StaticResolver{"intra": {"10.1.2.3"}, "www": {"192.0.2.1"}}))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	var got []string
+	ctx := WithLookupHandler(context.Background(), func(l Lookup) {
+		got = append(got, fmt.Sprintf("%s(%s) %v %v %s:%d", l.Func, l.Host, l.Addrs, l.Err == nil, l.Caller, l.Line))
+	})
+	r, err := p.FindProxyForURL(ctx, "http://www/")
+	if err != nil || r != "PROXY proxy:8080" {
+		t.Fatalf("FindProxyForURL() = %v, %v; want PROXY proxy:8080, nil", r, err)
+	}
+	// IPアドレスは名前解決しないため記録しない
+	want := []string{
+		"isInNet(www) [192.0.2.1] true isIntra:2",
+		"isResolvable(www) [192.0.2.1] true FindProxyForURL:6",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lookups = %q; want %q", got, want)
+	}
+
+	// WithDNSProfile を指定しない場合は記録しない
+	p, err = Load(strings.NewReader(`function FindProxyForURL(url, host) { return dnsResolve(host); }`),
+		WithResolver(StaticResolver{"www": {"192.0.2.1"}}))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+	got = nil
+	p.FindProxyForURL(ctx, "http://www/")
+	if len(got) != 0 {
+		t.Errorf("lookups = %q; want none", got)
+	}
+}
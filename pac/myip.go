@@ -0,0 +1,68 @@
+package pac
+
+import (
+	"net"
+	"sync"
+)
+
+// probeAddrs は外向きのインターフェースを調べるために経路を引く宛先 (文書用のアドレス)
+// UDPは接続しても送信しないため、実際に通信は発生しない
+var probeAddrs = []string{"192.0.2.1:80", "[2001:db8::1]:80"}
+
+// localAddrs はローカルホストのIPアドレス
+// 指定されていない場合は最初に参照したときに調べる
+type localAddrs struct {
+	once  sync.Once
+	addrs []string
+}
+
+func (l *localAddrs) get() []string {
+	l.once.Do(func() {
+		if l.addrs == nil {
+			l.addrs = detectLocalAddrs()
+		}
+	})
+	return l.addrs
+}
+
+// WithMyIPAddress は myIpAddress と myIpAddressEx が返すローカルホストのIPアドレスを指定する
+// 別の拠点のクライアントとしてPACスクリプトを評価するのに使用する
+func WithMyIPAddress(addrs ...string) Option {
+	return func(e *env) {
+		e.local = &localAddrs{addrs: addrs}
+	}
+}
+
+// detectLocalAddrs はローカルホストのIPアドレスを返す
+// 外向きの通信に使われるアドレスを先頭にし、他のインターフェースのアドレスを続ける
+func detectLocalAddrs() (r []string) {
+	seen := map[string]bool{}
+	add := func(ip net.IP) {
+		if ip == nil || !ip.IsGlobalUnicast() || seen[ip.String()] {
+			return
+		}
+		seen[ip.String()] = true
+		r = append(r, ip.String())
+	}
+
+	for _, addr := range probeAddrs {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			continue
+		}
+		add(conn.LocalAddr().(*net.UDPAddr).IP)
+		conn.Close()
+	}
+
+	ifAddrs, _ := net.InterfaceAddrs()
+	for _, addr := range ifAddrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			add(ipNet.IP)
+		}
+	}
+
+	if len(r) < 1 {
+		r = []string{"127.0.0.1"}
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package pac
+
+import (
+	"context"
+	"time"
+)
+
+// Override は FindProxyForURL の呼び出しごとに実行環境の設定の代わりに使う値
+// 一度コンパイルしたスクリプトを回帰テストのように条件を変えて評価するのに使用する
+type Override struct {
+	Resolver    Resolver // DNSの名前解決に使うリゾルバ (nil の場合は WithResolver の指定)
+	Clock       Clock    // 時刻に関する組み込み関数が参照する時計 (nil の場合は WithClock の指定)
+	MyIPAddress []string // myIpAddress と myIpAddressEx が返すアドレス (空の場合は WithMyIPAddress の指定)
+}
+
+// overrideKey は実行環境の設定の代わりに使う値のコンテクストのキー
+type overrideKey struct{}
+
+// WithOverride は ctx で FindProxyForURL を呼び出している間、
+// 実行環境の設定を o で置き換えるコンテクストを返す
+func WithOverride(ctx context.Context, o Override) context.Context {
+	return context.WithValue(ctx, overrideKey{}, o)
+}
+
+// override は評価中の呼び出しで置き換える値を返す
+func (e *env) override() (o Override) {
+	if e.ctx != nil {
+		o, _ = e.ctx.Value(overrideKey{}).(Override)
+	}
+	return
+}
+
+// lookupResolver は名前解決に使うリゾルバを返す
+func (e *env) lookupResolver() Resolver {
+	if r := e.override().Resolver; r != nil {
+		return r
+	}
+	return e.resolver
+}
+
+// now は時刻に関する組み込み関数が参照する現在時刻を返す
+func (e *env) now() time.Time {
+	if c := e.override().Clock; c != nil {
+		return c.Now()
+	}
+	return e.clock.Now()
+}
+
+// myAddrs はローカルホストのIPアドレスを返す
+func (e *env) myAddrs() []string {
+	if addrs := e.override().MyIPAddress; len(addrs) > 0 {
+		return addrs
+	}
+	return e.local.get()
+}
@@ -0,0 +1,43 @@
+package pac
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithOverride(t *testing.T) {
+	p, err := Load(strings.NewReader(`
+function FindProxyForURL(url, host) {
+    return [dnsResolve(host), myIpAddress(), timeRange(9, 18) ? "day" : "night"].join(" ");
+}
+`), WithResolver(StaticResolver{"intra": {"10.0.0.1"}}), WithMyIPAddress("10.1.0.10"),
+		WithClock(FixedClock(time.Date(2026, 12, 24, 12, 0, 0, 0, time.Local))))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		ctx  context.Context
+		want string
+	}{
+		{ctx, "10.0.0.1 10.1.0.10 day"},
+		{WithOverride(ctx, Override{
+			Resolver:    StaticResolver{"intra": {"10.0.0.2"}},
+			Clock:       FixedClock(time.Date(2026, 12, 24, 23, 0, 0, 0, time.Local)),
+			MyIPAddress: []string{"10.2.0.10"},
+		}), "10.0.0.2 10.2.0.10 night"},
+		// 指定しなかった値は実行環境の設定のまま
+		{WithOverride(ctx, Override{MyIPAddress: []string{"10.2.0.10"}}), "10.0.0.1 10.2.0.10 day"},
+		// 置き換えは呼び出しごと
+		{ctx, "10.0.0.1 10.1.0.10 day"},
+	}
+	for _, tt := range tests {
+		got, err := p.FindProxyForURL(tt.ctx, "http://intra/")
+		if err != nil || got != tt.want {
+			t.Errorf("FindProxyForURL() = %q, %v; want %q, nil", got, err, tt.want)
+		}
+	}
+}
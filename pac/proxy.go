@@ -0,0 +1,143 @@
+package pac
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ProxyType はFindProxyForURLの戻り値のキーワード
+type ProxyType string
+
+// FindProxyForURLの戻り値のキーワード
+const (
+	TypeDirect ProxyType = "DIRECT" // プロキシを使用せずに直接接続する
+	TypeProxy  ProxyType = "PROXY"  // 指定されたプロキシを使用する
+	TypeHTTP   ProxyType = "HTTP"   // 指定されたプロキシを使用する
+	TypeHTTPS  ProxyType = "HTTPS"  // 指定された HTTPS プロキシを使用する
+	TypeSOCKS  ProxyType = "SOCKS"  // 指定された SOCKS サーバーを使用する
+	TypeSOCKS4 ProxyType = "SOCKS4" // 指定された SOCKS4 サーバーを使用する
+	TypeSOCKS5 ProxyType = "SOCKS5" // 指定された SOCKS5 サーバーを使用する
+)
+
+var proxyTypes = []ProxyType{
+	TypeDirect, TypeProxy, TypeHTTP, TypeHTTPS, TypeSOCKS, TypeSOCKS4, TypeSOCKS5,
+}
+
+// Proxy はFindProxyForURLの戻り値に含まれるエントリ
+type Proxy struct {
+	Type ProxyType `json:"type"`
+	Host string    `json:"host,omitempty"`
+	Port int       `json:"port,omitempty"`
+}
+
+// Addr はプロキシの "host:port" を返す
+func (p Proxy) Addr() string {
+	if p.Type == TypeDirect {
+		return ""
+	}
+	return net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+}
+
+// String はエントリをFindProxyForURLの戻り値の書式で返す
+func (p Proxy) String() string {
+	if p.Type == TypeDirect {
+		return string(p.Type)
+	}
+	return string(p.Type) + " " + p.Addr()
+}
+
+// SyntaxError はFindProxyForURLの戻り値の構文エラー
+type SyntaxError struct {
+	Index int    // 何番目 (0から) のエントリか
+	Entry string // エラーとなったエントリ
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("entry %d %q: %s", e.Index+1, e.Entry, e.Msg)
+}
+
+// ParseProxies はFindProxyForURLの戻り値を解析し、エントリを順番に返す
+// "PROXY a:8080; SOCKS5 b:1080; DIRECT" => [PROXY a:8080] [SOCKS5 b:1080] [DIRECT]
+// 解析できないエントリがあった場合は最初のエラーを返すが、
+// ブラウザと同じくそのエントリを読み飛ばして残りのエントリも返す
+func ParseProxies(s string) (r []Proxy, err error) {
+	index := 0
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			// 末尾のセミコロンなどは読み飛ばす
+			continue
+		}
+		p, e := parseProxy(entry)
+		if e != nil {
+			if err == nil {
+				err = &SyntaxError{Index: index, Entry: entry, Msg: e.Error()}
+			}
+		} else {
+			r = append(r, p)
+		}
+		index++
+	}
+
+	if len(r) < 1 && err == nil {
+		err = fmt.Errorf("empty proxy list")
+	}
+	return
+}
+
+func parseProxy(entry string) (r Proxy, err error) {
+	fields := strings.Fields(entry)
+
+	keyword := ProxyType(strings.ToUpper(fields[0]))
+	if !subIsProxyType(keyword) {
+		err = fmt.Errorf("unknown keyword: %s", fields[0])
+		return
+	}
+	r.Type = keyword
+
+	if keyword == TypeDirect {
+		if len(fields) != 1 {
+			err = fmt.Errorf("DIRECT takes no address")
+		}
+		return
+	}
+
+	switch len(fields) {
+	case 1:
+		err = fmt.Errorf("missing address")
+		return
+	case 2:
+	default:
+		err = fmt.Errorf("too many fields")
+		return
+	}
+
+	var port string
+	r.Host, port, err = net.SplitHostPort(fields[1])
+	if err != nil {
+		err = fmt.Errorf("missing port: %s", fields[1])
+		return
+	}
+	if r.Host == "" {
+		err = fmt.Errorf("missing host: %s", fields[1])
+		return
+	}
+	r.Port, err = strconv.Atoi(port)
+	if err != nil || r.Port < 1 || r.Port > 65535 {
+		err = fmt.Errorf("abnormal port: %s", port)
+	}
+	return
+}
+
+func subIsProxyType(t ProxyType) (r bool) {
+	for _, pt := range proxyTypes {
+		if pt == t {
+			r = true
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package pac
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseProxies(t *testing.T) {
+	pats := map[string]string{
+		"DIRECT":                              "[DIRECT]",
+		"PROXY a:8080; SOCKS5 b:1080; DIRECT": "[PROXY a:8080 SOCKS5 b:1080 DIRECT]",
+		"proxy a:8080;":                       "[PROXY a:8080]",
+		"  HTTPS  a.b.c:443 ;HTTP [::1]:3128": "[HTTPS a.b.c:443 HTTP [::1]:3128]",
+		"SOCKS a:1080;SOCKS4 b:1081":          "[SOCKS a:1080 SOCKS4 b:1081]",
+	}
+	for s, want := range pats {
+		r, err := ParseProxies(s)
+		got := fmt.Sprint(r)
+		if err != nil || got != want {
+			t.Errorf("ParseProxies(%q) = %v, %v; want %v, nil", s, got, err, want)
+		}
+	}
+
+	r, _ := ParseProxies("SOCKS5 b:1080")
+	if r[0].Host != "b" || r[0].Port != 1080 || r[0].Type != TypeSOCKS5 {
+		t.Errorf("ParseProxies(\"SOCKS5 b:1080\") = %#v; want {SOCKS5 b 1080}", r[0])
+	}
+}
+
+func TestParseProxiesError(t *testing.T) {
+	// 構文エラーとなる戻り値と、読み飛ばされずに残るエントリ
+	pats := map[string]string{
+		"":                        "[]",
+		"PROXY a":                 "[]",
+		"PROXY":                   "[]",
+		"FTP a:21":                "[]",
+		"DIRECT a:80":             "[]",
+		"PROXY a:80 b:80":         "[]",
+		"PROXY a:http":            "[]",
+		"PROXY a:0":               "[]",
+		"PROXY :8080":             "[]",
+		"PROXY a:70000; DIRECT":   "[DIRECT]",
+		"PROXY a:80; FOO; b:8080": "[PROXY a:80]",
+	}
+	for s, want := range pats {
+		r, err := ParseProxies(s)
+		got := fmt.Sprint(r)
+		if err == nil || got != want {
+			t.Errorf("ParseProxies(%q) = %v, %v; want %v, !nil", s, got, err, want)
+		}
+	}
+
+	_, err := ParseProxies("PROXY a:80; FOO")
+	if e, ok := err.(*SyntaxError); !ok || e.Index != 1 || e.Entry != "FOO" {
+		t.Errorf("ParseProxies(\"PROXY a:80; FOO\") = _, %#v; want SyntaxError at entry 2", err)
+	}
+}
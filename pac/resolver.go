@@ -0,0 +1,160 @@
+package pac
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Resolver はホスト名をIPアドレスに解決するインターフェース
+// *net.Resolver はこのインターフェースを満たす
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// StaticResolver はホスト名とIPアドレスの固定の対応表によるリゾルバ
+// ホスト名は大文字と小文字を区別しない
+type StaticResolver map[string][]string
+
+// LookupHost は対応表からホスト名を解決する
+// 完全に一致するホスト名がなく、大文字と小文字だけが異なるホスト名が複数ある場合は、ホスト名の順にアドレスをまとめて返す
+func (s StaticResolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	addrs = s[host]
+	if len(addrs) < 1 {
+		var names []string
+		for name := range s {
+			if strings.EqualFold(name, host) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			addrs = append(addrs, s[name]...)
+		}
+	}
+	if len(addrs) < 1 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return
+}
+
+// NewHostsResolver は hosts ファイルの書式のファイルからリゾルバを生成する
+func NewHostsResolver(filePath string) (r StaticResolver, err error) {
+	var f *os.File
+	f, err = os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	r = StaticResolver{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 1 {
+			continue
+		}
+		if net.ParseIP(fields[0]) == nil || len(fields) < 2 {
+			err = fmt.Errorf("%s:%d: abnormal line", filePath, lineNo)
+			return
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(name)
+			r[name] = append(r[name], fields[0])
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// NewDNSResolver は指定したDNSサーバに問い合わせるリゾルバを生成する
+// server のポート番号を省略した場合は 53 番を使用する
+func NewDNSResolver(server string) (r *net.Resolver) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	r = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	return
+}
+
+// MultiResolver は順にリゾルバに問い合わせ、最初に解決できたアドレスを返すリゾルバ
+type MultiResolver []Resolver
+
+// LookupHost はいずれかのリゾルバで解決できるまで順に問い合わせる
+func (m MultiResolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	for _, r := range m {
+		addrs, err = r.LookupHost(ctx, host)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// CachingResolver は最初に解決した結果を覚えておき、同じホスト名には同じ結果を返すリゾルバ
+// ラウンドロビンのDNSでも複数の評価で同じアドレスを使うのに使用する
+type CachingResolver struct {
+	resolver Resolver
+
+	mu    sync.Mutex
+	cache map[string]cachedLookup
+}
+
+// cachedLookup は覚えておく名前解決の結果
+type cachedLookup struct {
+	addrs []string
+	err   error
+}
+
+// NewCachingResolver は resolver の結果を覚えておくリゾルバを生成する
+func NewCachingResolver(resolver Resolver) *CachingResolver {
+	return &CachingResolver{
+		resolver: resolver,
+		cache:    map[string]cachedLookup{},
+	}
+}
+
+// LookupHost は覚えている結果を返し、なければ解決して覚える
+func (c *CachingResolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	key := strings.ToLower(host)
+	c.mu.Lock()
+	cached, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		addrs, err = cached.addrs, cached.err
+		return
+	}
+
+	addrs, err = c.resolver.LookupHost(ctx, host)
+	if ctx.Err() != nil {
+		// 中断された結果は覚えない
+		return
+	}
+	c.mu.Lock()
+	if cached, ok = c.cache[key]; ok {
+		// 並行に解決した結果がある場合はそちらにそろえる
+		addrs, err = cached.addrs, cached.err
+	} else {
+		c.cache[key] = cachedLookup{addrs, err}
+	}
+	c.mu.Unlock()
+	return
+}
@@ -0,0 +1,235 @@
+package pac
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHostsResolver(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "hosts")
+	hosts := "# comment\n127.0.0.1 localhost\n\n10.0.0.1 intra Intra.Foo.co.jp # inline\n10.0.0.2 intra\n"
+	if err := os.WriteFile(filePath, []byte(hosts), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewHostsResolver(filePath)
+	if err != nil {
+		t.Fatalf("NewHostsResolver() = _, %v; want nil", err)
+	}
+
+	pats := map[string]string{
+		"localhost":       "127.0.0.1",
+		"intra":           "10.0.0.1,10.0.0.2",
+		"intra.foo.co.jp": "10.0.0.1",
+		"INTRA.FOO.CO.JP": "10.0.0.1",
+		"www":             "",
+	}
+	for host, want := range pats {
+		addrs, _ := r.LookupHost(context.Background(), host)
+		got := strings.Join(addrs, ",")
+		if got != want {
+			t.Errorf("LookupHost(%s) = %v; want %v", host, got, want)
+		}
+	}
+
+	if err := os.WriteFile(filePath, []byte("localhost 127.0.0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewHostsResolver(filePath); err == nil {
+		t.Errorf("NewHostsResolver() = _, nil; want !nil")
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	r := StaticResolver{
+		"Example.COM": {"192.0.2.1"},
+		"intra":       {"10.0.0.1"},
+		"INTRA":       {"10.0.0.2"},
+		"Intra":       {"10.0.0.3"},
+	}
+	pats := map[string]string{
+		"example.com": "192.0.2.1",
+		"EXAMPLE.com": "192.0.2.1",
+		"intra":       "10.0.0.1",
+		"iNtRa":       "10.0.0.2,10.0.0.3,10.0.0.1",
+		"www":         "",
+	}
+	for host, want := range pats {
+		addrs, err := r.LookupHost(context.Background(), host)
+		got := strings.Join(addrs, ",")
+		if got != want || (err == nil) != (want != "") {
+			t.Errorf("LookupHost(%s) = %v, %v; want %v", host, got, err, want)
+		}
+	}
+}
+
+func TestWithResolver(t *testing.T) {
+	script := `
+function FindProxyForURL(url, host) {
+    if (isInNet(host, "10.0.0.0", "255.0.0.0")) {
+        return "DIRECT";
+    }
+    if (isResolvable(host)) {
+        return "PROXY " + dnsResolve(host) + ":8080";
+    }
+    return "PROXY proxy:8080";
+}
+`
+	p, err := Load(strings.NewReader(script), WithResolver(StaticResolver{
+		"intra":   {"10.1.2.3"},
+		"example": {"192.0.2.1"},
+	}))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	pats := map[string]string{
+		"http://intra/":       "DIRECT",
+		"http://example/":     "PROXY 192.0.2.1:8080",
+		"http://unknown/":     "PROXY proxy:8080",
+		"http://10.20.30.40/": "DIRECT",
+	}
+	for rawurl, want := range pats {
+		got, err := p.FindProxyForURL(context.Background(), rawurl)
+		if err != nil || got != want {
+			t.Errorf("FindProxyForURL(%s) = %v, %v; want %v, nil", rawurl, got, err, want)
+		}
+	}
+}
+
+// serveDNS は A レコードの問い合わせに hosts の対応表で答えるDNSサーバを起動する
+func serveDNS(t *testing.T, hosts map[string]string) (addr string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 12 {
+				continue
+			}
+			// 質問部の名前を読む
+			q := buf[:n]
+			labels := []string{}
+			i := 12
+			for i < n && q[i] != 0 {
+				l := int(q[i])
+				if i+1+l > n {
+					break
+				}
+				labels = append(labels, string(q[i+1:i+1+l]))
+				i += 1 + l
+			}
+			end := i + 5 // 名前の終わりの 0、タイプとクラス
+			if end > n {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(q[i+1:])
+
+			resp := append([]byte{}, q[:2]...)
+			resp = append(resp, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+			resp = append(resp, q[12:end]...)
+			ip := net.ParseIP(hosts[strings.Join(labels, ".")]).To4()
+			switch {
+			case ip == nil:
+				resp[3] = 0x83 // NXDOMAIN
+			case qtype == 1:
+				resp[7] = 1
+				resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				resp = append(resp, ip...)
+			}
+			conn.WriteTo(resp, peer)
+		}
+	}()
+
+	addr = conn.LocalAddr().String()
+	return
+}
+
+func TestNewDNSResolver(t *testing.T) {
+	r := NewDNSResolver(serveDNS(t, map[string]string{
+		"intra.foo.co.jp": "10.1.2.3",
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := r.LookupHost(ctx, "intra.foo.co.jp")
+	if err != nil || strings.Join(addrs, ",") != "10.1.2.3" {
+		t.Errorf("LookupHost(intra.foo.co.jp) = %v, %v; want [10.1.2.3], nil", addrs, err)
+	}
+	_, err = r.LookupHost(ctx, "www.foo.co.jp")
+	if err == nil {
+		t.Errorf("LookupHost(www.foo.co.jp) = _, nil; want !nil")
+	}
+}
+
+func TestMultiResolver(t *testing.T) {
+	r := MultiResolver{
+		StaticResolver{"intra": {"10.0.0.1"}},
+		StaticResolver{"intra": {"10.0.0.2"}, "www": {"192.0.2.1"}},
+	}
+	pats := map[string]string{
+		"intra": "10.0.0.1",
+		"www":   "192.0.2.1",
+		"none":  "",
+	}
+	for host, want := range pats {
+		addrs, err := r.LookupHost(context.Background(), host)
+		got := strings.Join(addrs, ",")
+		if got != want || (err == nil) != (want != "") {
+			t.Errorf("LookupHost(%s) = %v, %v; want %v", host, got, err, want)
+		}
+	}
+	if _, err := (MultiResolver{}).LookupHost(context.Background(), "www"); err == nil {
+		t.Errorf("MultiResolver{}.LookupHost() = _, nil; want !nil")
+	}
+}
+
+// roundRobinResolver は呼び出すたびに異なるアドレスを返すリゾルバ
+type roundRobinResolver struct {
+	n *int
+}
+
+func (r roundRobinResolver) LookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	*r.n++
+	addrs = []string{fmt.Sprintf("10.0.0.%d", *r.n)}
+	return
+}
+
+func TestCachingResolver(t *testing.T) {
+	n := 0
+	r := NewCachingResolver(roundRobinResolver{&n})
+	ctx := context.Background()
+
+	pats := map[string]string{}
+	for i := 0; i < 3; i++ {
+		for _, host := range []string{"www", "intra", "WWW"} {
+			addrs, err := r.LookupHost(ctx, host)
+			if err != nil || len(addrs) != 1 {
+				t.Fatalf("LookupHost(%s) = %v, %v; want 1 address, nil", host, addrs, err)
+			}
+			key := strings.ToLower(host)
+			if want, ok := pats[key]; ok && addrs[0] != want {
+				t.Errorf("LookupHost(%s) = %v; want %v", host, addrs[0], want)
+			}
+			pats[key] = addrs[0]
+		}
+	}
+	if n != 2 {
+		t.Errorf("lookups = %d; want 2", n)
+	}
+}
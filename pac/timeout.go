@@ -0,0 +1,90 @@
+package pac
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// TimeoutError はPACスクリプトの実行が ctx の終了により中断されたことを表すエラー
+// errors.Is(err, context.DeadlineExceeded) で判別できる
+type TimeoutError struct {
+	Name string // PACスクリプトのファイル名
+	Err  error  // ctx.Err()
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Name + ": script execution interrupted: " + e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// WithTimeout はPACスクリプトの読み込みと FindProxyForURL の1回の呼び出しの制限時間を指定する
+// 0 の場合は制限しない
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *env) {
+		e.timeout = timeout
+	}
+}
+
+// withTimeout は実行環境の制限時間を ctx に設定する
+func (e *env) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.timeout)
+}
+
+// interrupted は Interrupt で実行を中断したときの panic の値
+type interrupted struct{}
+
+// runContext は ctx が終了したら vm での fn の実行を中断する
+// 中断した場合は *TimeoutError を返す
+// 組み込み関数などで panic が発生した場合は *ScriptError を返す
+// いずれの場合も vm はそれ以降使用できない
+func runContext(ctx context.Context, name string, vm *otto.Otto, fn func() (otto.Value, error)) (value otto.Value, err error) {
+	defer func() {
+		if c := recover(); c != nil {
+			if _, ok := c.(interrupted); ok {
+				err = &TimeoutError{Name: name, Err: ctx.Err()}
+				return
+			}
+			err = &ScriptError{Name: name, Message: fmt.Sprintf("panic: %v", c)}
+		}
+	}()
+
+	if ctx.Done() == nil {
+		value, err = fn()
+		err = scriptError(name, err)
+		return
+	}
+
+	interrupt := make(chan func(), 1)
+	vm.Interrupt = interrupt
+	stop := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			interrupt <- func() {
+				panic(interrupted{})
+			}
+		case <-stop:
+		}
+	}()
+
+	defer func() {
+		close(stop)
+		<-exited
+		vm.Interrupt = nil
+	}()
+
+	value, err = fn()
+	err = scriptError(name, err)
+	return
+}
@@ -0,0 +1,48 @@
+package pac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	_, err := Load(strings.NewReader("while (true) {}"), WithTimeout(50*time.Millisecond))
+	var te *TimeoutError
+	if !errors.As(err, &te) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Load(while (true) {}) = _, %v; want *TimeoutError", err)
+	}
+
+	p, err := Load(strings.NewReader(`
+function FindProxyForURL(url, host) {
+    if (host == "loop") {
+        while (true) {}
+    }
+    return "DIRECT";
+}
+`), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	_, err = p.FindProxyForURL(context.Background(), "http://loop/")
+	if !errors.As(err, &te) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FindProxyForURL(http://loop/) = _, %v; want *TimeoutError", err)
+	}
+
+	// 呼び出し側のコンテクストの終了でも中断する (制限時間より先に終了させる)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(5*time.Millisecond, cancel)
+	_, err = p.FindProxyForURL(ctx, "http://loop/")
+	if !errors.As(err, &te) || !errors.Is(err, context.Canceled) {
+		t.Errorf("FindProxyForURL(http://loop/) = _, %v; want *TimeoutError", err)
+	}
+
+	// 中断した後も評価できる
+	got, err := p.FindProxyForURL(context.Background(), "http://hoge/")
+	if err != nil || got != "DIRECT" {
+		t.Errorf("FindProxyForURL(http://hoge/) = %v, %v; want DIRECT, nil", got, err)
+	}
+}
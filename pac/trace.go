@@ -0,0 +1,118 @@
+package pac
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// Call は評価中に呼び出された組み込み関数の記録
+type Call struct {
+	Name     string        // 関数名
+	Args     []interface{} // 引数
+	Result   interface{}   // 戻り値 (戻り値のない関数は nil)
+	Duration time.Duration // 所要時間
+}
+
+// String は `isInNet("10.1.2.3", "10.0.0.0", "255.0.0.0") -> true` の形式で呼び出しを返す
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = formatValue(arg)
+	}
+	r := c.Name + "(" + strings.Join(args, ", ") + ")"
+	if c.Result != nil {
+		r += " -> " + formatValue(c.Result)
+	}
+	return r
+}
+
+func formatValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// WithTrace は組み込み関数の呼び出しを記録できるようにする
+// 記録は WithTraceHandler で指定した関数に渡される
+func WithTrace() Option {
+	return func(e *env) {
+		e.trace = true
+	}
+}
+
+// traceHandlerKey は組み込み関数の呼び出しの記録を受け取る関数のコンテクストのキー
+type traceHandlerKey struct{}
+
+// WithTraceHandler は ctx で FindProxyForURL を呼び出している間の組み込み関数の呼び出しを
+// handler に渡すコンテクストを返す (WithTrace を指定したPACスクリプトのみ)
+func WithTraceHandler(ctx context.Context, handler func(c Call)) context.Context {
+	return context.WithValue(ctx, traceHandlerKey{}, handler)
+}
+
+// traced は呼び出しを記録するように組み込み関数をラップする
+func (e *env) traced(name string, fn interface{}) interface{} {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fn
+	}
+
+	return reflect.MakeFunc(v.Type(), func(args []reflect.Value) (results []reflect.Value) {
+		var handler func(Call)
+		if e.ctx != nil {
+			handler, _ = e.ctx.Value(traceHandlerKey{}).(func(Call))
+		}
+
+		start := time.Now()
+		if v.Type().IsVariadic() {
+			results = v.CallSlice(args)
+		} else {
+			results = v.Call(args)
+		}
+		if handler == nil {
+			return
+		}
+
+		c := Call{
+			Name:     name,
+			Duration: time.Since(start),
+		}
+		for i, arg := range args {
+			if v.Type().IsVariadic() && i == len(args)-1 {
+				for j := 0; j < arg.Len(); j++ {
+					c.Args = append(c.Args, arg.Index(j).Interface())
+				}
+				continue
+			}
+			if call, ok := arg.Interface().(otto.FunctionCall); ok {
+				for _, a := range call.ArgumentList {
+					c.Args = append(c.Args, exportValue(a))
+				}
+				continue
+			}
+			c.Args = append(c.Args, arg.Interface())
+		}
+		if len(results) > 0 {
+			c.Result = exportValue(results[0].Interface())
+		}
+		handler(c)
+		return
+	}).Interface()
+}
+
+// exportValue はJavaScriptの値を記録用のGoの値に変換する
+func exportValue(v interface{}) interface{} {
+	if x, ok := v.(otto.Value); ok {
+		if x.IsUndefined() {
+			return nil
+		}
+		r, _ := x.Export()
+		return r
+	}
+	return v
+}
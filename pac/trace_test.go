@@ -0,0 +1,46 @@
+package pac
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	p, err := Load(strings.NewReader(`
+function FindProxyForURL(url, host) {
+    alert("checking " + host);
+    if (isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0") && timeRange(8, 18)) {
+        return "PROXY proxy1:8080";
+    }
+    return "DIRECT";
+}
+`), WithTrace(), WithResolver(StaticResolver{"intra": {"10.1.2.3"}}), WithAlertLogger(nil))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+
+	var got []string
+	ctx := WithTraceHandler(context.Background(), func(c Call) {
+		got = append(got, c.String())
+	})
+	_, err = p.FindProxyForURL(ctx, "http://intra/")
+	if err != nil {
+		t.Fatalf("FindProxyForURL() = _, %v; want nil", err)
+	}
+	want := []string{
+		`alert("checking intra")`,
+		`dnsResolve("intra") -> "10.1.2.3"`,
+		`isInNet("10.1.2.3", "10.0.0.0", "255.0.0.0") -> true`,
+	}
+	if len(got) != 4 || !reflect.DeepEqual(got[:3], want) || !strings.HasPrefix(got[3], "timeRange(8, 18) -> ") {
+		t.Errorf("trace = %q; want %q and timeRange(8, 18)", got, want)
+	}
+
+	// ハンドラを指定しない場合は記録しない
+	r, err := p.FindProxyForURL(context.Background(), "http://hoge/")
+	if err != nil || r != "DIRECT" {
+		t.Errorf("FindProxyForURL(http://hoge/) = %v, %v; want DIRECT, nil", r, err)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package pac
 
 import (
 	"fmt"
@@ -125,8 +125,8 @@ isInNet()
 構文
 */
 
-func isResolvable(host string) (r bool) {
-	_, err := net.LookupHost(host)
+func (e *env) isResolvable(host string) (r bool) {
+	_, err := e.lookupHost("isResolvable", host)
 	if err != nil {
 		return
 	}
@@ -155,13 +155,13 @@ function FindProxyForURL(url, host) {
 }
 */
 
-func isInNet(host, pattern, mask string) (r bool) {
+func (e *env) isInNet(host, pattern, mask string) (r bool) {
 	ipNet := &net.IPNet{
 		IP:   net.ParseIP(pattern),
 		Mask: net.IPMask(net.ParseIP(mask)),
 	}
 
-	addrs, err := net.LookupHost(host)
+	addrs, err := e.lookupHost("isInNet", host)
 	if err != nil {
 		return
 	}
@@ -190,9 +190,9 @@ Example
 dnsResolve("www.mozilla.org"); // returns the string "104.16.41.2"
 */
 
-func dnsResolve(host string) (r string) {
-	addrs, err := net.LookupHost(host)
-	if err != nil {
+func (e *env) dnsResolve(host string) (r string) {
+	addrs, err := e.lookupHost("dnsResolve", host)
+	if err != nil || len(addrs) < 1 {
 		return
 	}
 	r = addrs[0]
@@ -234,7 +234,15 @@ Example
 myIpAddress() //returns the string "127.0.1.1" if you were running Firefox on that localhost
 */
 
-func myIPAddress() (r string) {
+func (e *env) myIPAddress() (r string) {
+	// IPv4 のアドレスを返す
+	addrs := e.myAddrs()
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			r = addr
+			return
+		}
+	}
 	r = "127.0.0.1"
 	return
 }
@@ -326,9 +334,9 @@ weekdayRange("SAT", "GMT");        // returns true on Saturdays GMT time
 weekdayRange("FRI", "MON");        // returns true Friday and Monday only (note, order does matter!)
 */
 
-func weekdayRange(params ...string) (r bool) {
+func (e *env) weekdayRange(params ...string) (r bool) {
 	var err error
-	r, err = subWeekdayRange(time.Now(), params...)
+	r, err = subWeekdayRange(e.now(), params...)
 	if err != nil {
 		panic(err)
 	}
@@ -437,8 +445,8 @@ dateRange(1995, 1997);
 // returns true from beginning of year 1995 until the end of year 1997
 */
 
-func dateRange(params ...interface{}) bool {
-	r, err := subDateRange(time.Now(), params...)
+func (e *env) dateRange(params ...interface{}) bool {
+	r, err := subDateRange(e.now(), subIntParams(params)...)
 	if err != nil {
 		panic(err)
 	}
@@ -452,7 +460,7 @@ func subDateRange(now time.Time, params ...interface{}) (r bool, err error) {
 		return
 	}
 
-	loc := time.Local
+	loc := now.Location() // ローカルタイムゾーンは now のタイムゾーンとする
 	stmp, ok := params[len(params)-1].(string)
 	if ok && stmp == "GMT" {
 		params = params[0 : len(params)-1]
@@ -555,6 +563,26 @@ func subDateRange(now time.Time, params ...interface{}) (r bool, err error) {
 	return
 }
 
+// subIntParams はJavaScriptから渡された整数値の引数を int に変換する
+func subIntParams(params []interface{}) (r []interface{}) {
+	r = make([]interface{}, len(params))
+	for i, p := range params {
+		switch n := p.(type) {
+		case int64:
+			r[i] = int(n)
+		case float64:
+			if n == float64(int(n)) {
+				r[i] = int(n)
+			} else {
+				r[i] = n
+			}
+		default:
+			r[i] = p
+		}
+	}
+	return
+}
+
 func subIsDay(t interface{}) (r bool) {
 	n, ok := t.(int)
 	if !ok {
@@ -628,8 +656,8 @@ timerange(0, 0, 0, 0, 0, 30); // returns true between midnight and 30 seconds pa
 
 */
 
-func timeRange(params ...interface{}) bool {
-	r, err := subTimeRange(time.Now(), params...)
+func (e *env) timeRange(params ...interface{}) bool {
+	r, err := subTimeRange(e.now(), subIntParams(params)...)
 	if err != nil {
 		panic(err)
 	}
@@ -638,7 +666,7 @@ func timeRange(params ...interface{}) bool {
 
 func subTimeRange(now time.Time, params ...interface{}) (r bool, err error) {
 	var nums []int
-	loc := time.Local
+	loc := now.Location() // ローカルタイムゾーンは now のタイムゾーンとする
 
 	for i, p := range params {
 		switch p.(type) {
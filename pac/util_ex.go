@@ -0,0 +1,152 @@
+package pac
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+)
+
+// IPv6 に対応した Microsoft の拡張関数
+// https://learn.microsoft.com/en-us/windows/win32/winhttp/ipv6-extensions-to-navigator-auto-config-file-format
+
+/*
+isResolvableEx(host)
+Parameters
+host
+The host name to resolve.
+Returns true if the host name can be resolved to one or more IPv4 or IPv6 addresses.
+
+Example
+isResolvableEx("www.microsoft.com") // true
+*/
+
+func (e *env) isResolvableEx(host string) (r bool) {
+	_, err := e.lookupHost("isResolvableEx", host)
+	r = err == nil
+	return
+}
+
+/*
+dnsResolveEx(host)
+Parameters
+host
+The host name to resolve.
+Returns a semicolon-separated list of the IPv4 and IPv6 addresses of the host,
+or an empty string if it cannot be resolved.
+
+Example
+dnsResolveEx("www.microsoft.com") // "2001:db8::1;192.0.2.1"
+*/
+
+func (e *env) dnsResolveEx(host string) (r string) {
+	addrs, err := e.lookupHost("dnsResolveEx", host)
+	if err != nil {
+		return
+	}
+	r = strings.Join(addrs, ";")
+	return
+}
+
+/*
+myIpAddressEx()
+Returns a semicolon-separated list of the IPv4 and IPv6 addresses of the local host.
+
+Example
+myIpAddressEx() // "2001:db8::100;192.0.2.100"
+*/
+
+func (e *env) myIPAddressEx() (r string) {
+	r = strings.Join(e.myAddrs(), ";")
+	return
+}
+
+/*
+isInNetEx(host, prefix)
+Parameters
+host
+A host name or an IPv4 or IPv6 address. A host name is resolved by this function.
+prefix
+An IP prefix in CIDR notation, e.g. "198.95.0.0/16" or "3ffe:8311:ffff::/48".
+Returns true if an address of the host is within the prefix.
+
+Example
+isInNetEx(host, "198.95.249.79/32") // true if the IP address of host matches exactly 198.95.249.79
+isInNetEx(host, "198.95.0.0/16")    // true if the IP address of the host matches 198.95.*.*
+*/
+
+func (e *env) isInNetEx(host, prefix string) (r bool) {
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return
+	}
+
+	addrs, err := e.lookupHost("isInNetEx", host)
+	if err != nil {
+		return
+	}
+
+	for _, addr := range addrs {
+		if ipNet.Contains(net.ParseIP(addr)) {
+			r = true
+			break
+		}
+	}
+	return
+}
+
+/*
+sortIpAddressList(list)
+Parameters
+list
+A semicolon-separated list of IPv4 and IPv6 addresses.
+Returns the list sorted with the IPv6 addresses first, or false if the list contains an invalid address.
+
+Example
+sortIpAddressList("10.2.3.9;2001:4898:28:3:201:2ff:feea:fc14;157.59.139.22;fe80::5efe:157:9d3b:8b16")
+// "2001:4898:28:3:201:2ff:feea:fc14;fe80::5efe:157:9d3b:8b16;10.2.3.9;157.59.139.22"
+*/
+
+func sortIPAddressList(list string) (r interface{}) {
+	r = false
+
+	addrs := strings.Split(list, ";")
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		addrs[i] = strings.TrimSpace(addr)
+		ips[i] = net.ParseIP(addrs[i])
+		if ips[i] == nil {
+			return
+		}
+	}
+
+	idx := make([]int, len(addrs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ipA, ipB := ips[idx[a]], ips[idx[b]]
+		v4A, v4B := ipA.To4() != nil, ipB.To4() != nil
+		if v4A != v4B {
+			return v4B
+		}
+		return bytes.Compare(ipA.To16(), ipB.To16()) < 0
+	})
+
+	sorted := make([]string, len(idx))
+	for i, j := range idx {
+		sorted[i] = addrs[j]
+	}
+	r = strings.Join(sorted, ";")
+	return
+}
+
+/*
+getClientVersion()
+Returns the version of the IPv6 extensions supported ("1.0").
+*/
+
+func getClientVersion() (r string) {
+	r = "1.0"
+	return
+}
@@ -0,0 +1,107 @@
+package pac
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var testResolverEx = StaticResolver{
+	"www.mozilla.org": {"2606:4700::6810:2902", "104.16.41.2"},
+	"www.isc2.org":    {"107.162.133.105"},
+}
+
+func TestIsResolvableEx(t *testing.T) {
+	e := newEnv(WithResolver(testResolverEx))
+	pats := map[string]bool{
+		"www.mozilla.org": true,
+		"::1":             true,
+		"www":             false,
+	}
+	for host, want := range pats {
+		got := e.isResolvableEx(host)
+		if got != want {
+			t.Errorf("isResolvableEx(%s) = %v; want %v", host, got, want)
+		}
+	}
+}
+
+func TestDnsResolveEx(t *testing.T) {
+	e := newEnv(WithResolver(testResolverEx))
+	pats := map[string]string{
+		"www.mozilla.org": "2606:4700::6810:2902;104.16.41.2",
+		"www.isc2.org":    "107.162.133.105",
+		"www":             "",
+	}
+	for host, want := range pats {
+		got := e.dnsResolveEx(host)
+		if got != want {
+			t.Errorf("dnsResolveEx(%s) = %v; want %v", host, got, want)
+		}
+	}
+}
+
+func TestMyIPAddressEx(t *testing.T) {
+	e := newEnv(WithMyIPAddress("2001:db8::100", "192.0.2.100"))
+	want := "2001:db8::100;192.0.2.100"
+	got := e.myIPAddressEx()
+	if got != want {
+		t.Errorf("myIPAddressEx() = %v; want %v", got, want)
+	}
+}
+
+func TestIsInNetEx(t *testing.T) {
+	e := newEnv(WithResolver(testResolverEx))
+	pats := map[[2]string]bool{
+		{"198.95.249.79", "198.95.249.79/32"}:        true,
+		{"198.95.1.2", "198.95.0.0/16"}:              true,
+		{"198.96.1.2", "198.95.0.0/16"}:              false,
+		{"3ffe:8311:ffff::1", "3ffe:8311:ffff::/48"}: true,
+		{"www.mozilla.org", "2606:4700::/32"}:        true,
+		{"www.mozilla.org", "104.16.0.0/12"}:         true,
+		{"www.isc2.org", "2606:4700::/32"}:           false,
+		{"198.95.1.2", "198.95.0.0"}:                 false,
+		{"www", "0.0.0.0/0"}:                         false,
+	}
+	for args, want := range pats {
+		got := e.isInNetEx(args[0], args[1])
+		if got != want {
+			t.Errorf("isInNetEx(%s, %s) = %v; want %v", args[0], args[1], got, want)
+		}
+	}
+}
+
+func TestSortIPAddressList(t *testing.T) {
+	pats := map[string]interface{}{
+		"10.2.3.9;2001:4898:28:3:201:2ff:feea:fc14;3ffe:8311:ffff:1:0:0:0:80;fe80::5efe:157:9d3b:8b16;157.59.139.22;fe80::c0dc:2ad:a9d1:8d8b": "2001:4898:28:3:201:2ff:feea:fc14;3ffe:8311:ffff:1:0:0:0:80;fe80::5efe:157:9d3b:8b16;fe80::c0dc:2ad:a9d1:8d8b;10.2.3.9;157.59.139.22",
+		"10.0.0.2;9.0.0.1": "9.0.0.1;10.0.0.2",
+		"10.0.0.1;hoge":    false,
+		"":                 false,
+	}
+	for list, want := range pats {
+		got := sortIPAddressList(list)
+		if got != want {
+			t.Errorf("sortIpAddressList(%s) = %v; want %v", list, got, want)
+		}
+	}
+}
+
+func TestExBuiltIns(t *testing.T) {
+	p, err := Load(strings.NewReader(`
+function FindProxyForURL(url, host) {
+    if (getClientVersion() == "1.0" && isInNetEx(host, "2606:4700::/32") &&
+        sortIpAddressList(dnsResolveEx(host)) == "2606:4700::6810:2902;104.16.41.2" &&
+        sortIpAddressList(myIpAddressEx()) !== false && isResolvableEx(host)) {
+        return "PROXY proxy6:8080";
+    }
+    return "DIRECT";
+}
+`), WithResolver(testResolverEx))
+	if err != nil {
+		t.Fatalf("Load() = _, %v; want nil", err)
+	}
+	got, err := p.FindProxyForURL(context.Background(), "http://www.mozilla.org/")
+	if err != nil || got != "PROXY proxy6:8080" {
+		t.Errorf("FindProxyForURL(http://www.mozilla.org/) = %v, %v; want PROXY proxy6:8080, nil", got, err)
+	}
+}
@@ -1,6 +1,7 @@
-package main
+package pac
 
 import (
+	"net"
 	"testing"
 	"time"
 )
@@ -46,13 +47,21 @@ func TestLocalHostOrDomainIs(t *testing.T) {
 	}
 }
 
+// testResolver はテスト用の固定のリゾルバ
+var testResolver = StaticResolver{
+	"www.mozilla.org": {"104.16.41.2"},
+	"www.isc2.org":    {"107.162.133.105"},
+	"intra.foo.co.jp": {"63.245.213.3"},
+}
+
 func TestIsResolvable(t *testing.T) {
+	e := newEnv(WithResolver(testResolver))
 	pats := map[string]bool{
 		"www.mozilla.org": true,
 		"www":             false,
 	}
 	for host, want := range pats {
-		got := isResolvable(host)
+		got := e.isResolvable(host)
 		if got != want {
 			t.Errorf("isResolvable(%s) = %v; want %v", host, got, want)
 		}
@@ -60,14 +69,17 @@ func TestIsResolvable(t *testing.T) {
 }
 
 func TestIsInNet(t *testing.T) {
+	e := newEnv(WithResolver(testResolver))
 	pats := map[[3]string]bool{
 		{"63.245.213.3", "63.245.213.24", "255.255.255.0"}:    true,
 		{"63.245.213.24", "63.245.213.24", "255.255.255.255"}: true,
 		{"63.245.213.3", "63.245.213.24", "255.255.255.255"}:  false,
+		{"intra.foo.co.jp", "63.245.213.0", "255.255.255.0"}:  true,
+		{"www", "63.245.213.0", "255.255.255.0"}:              false,
 		//[3]string{}:      true,
 	}
 	for args, want := range pats {
-		got := isInNet(args[0], args[1], args[2])
+		got := e.isInNet(args[0], args[1], args[2])
 		if got != want {
 			t.Errorf("isInNet(%s, %s, %s) = %v; want %v", args[0], args[1], args[2], got, want)
 		}
@@ -75,11 +87,13 @@ func TestIsInNet(t *testing.T) {
 }
 
 func TestDnsResolve(t *testing.T) {
+	e := newEnv(WithResolver(testResolver))
 	pats := map[string]string{
 		"www.isc2.org": "107.162.133.105",
+		"www":          "",
 	}
 	for host, want := range pats {
-		got := dnsResolve(host)
+		got := e.dnsResolve(host)
 		if got != want {
 			t.Errorf("dnsResolve(%s) = %v; want %v", host, got, want)
 		}
@@ -99,10 +113,22 @@ func TestConvertAddr(t *testing.T) {
 }
 
 func TestMyIPAddress(t *testing.T) {
-	want := "127.0.0.1"
-	got := myIPAddress()
-	if got != want {
-		t.Errorf("myIPAddress() = %v; want %v", got, want)
+	pats := map[string][]string{
+		"10.20.30.40": {"10.20.30.40"},
+		"10.0.0.1":    {"2001:db8::1", "10.0.0.1"},
+		"127.0.0.1":   {"2001:db8::1"},
+	}
+	for want, addrs := range pats {
+		got := newEnv(WithMyIPAddress(addrs...)).myIPAddress()
+		if got != want {
+			t.Errorf("myIPAddress() with %v = %v; want %v", addrs, got, want)
+		}
+	}
+
+	// 指定しない場合はローカルホストのIPv4アドレス
+	got := newEnv().myIPAddress()
+	if ip := net.ParseIP(got); ip == nil || ip.To4() == nil {
+		t.Errorf("myIPAddress() = %v; want an IPv4 address", got)
 	}
 }
 
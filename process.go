@@ -1,25 +1,182 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
 )
 
-func process(scriptFilePath string, urls []string) (err error) {
+func process(conf config) (err error) {
+
+	ctx := context.Background()
+
+	var p *pac.PAC
+	p, err = loadPAC(ctx, conf)
+	if err != nil {
+		return
+	}
 
-	var ctx *JSCtx
-	ctx, err = NewJSCtx(scriptFilePath)
+	var w recordWriter
+	w, err = newRecordWriter(conf.format, os.Stdout, conf.parse)
 	if err != nil {
 		return
 	}
 
-	for _, urlStr := range urls {
-		u, e := url.Parse(urlStr)
-		if e != nil {
-			err = e
-			break
+	// 評価に失敗したURLは全てのURLを処理した後で報告する
+	var total, failed int
+	profile := newDNSProfile(p.Name())
+	err = evaluateAll(ctx, p, conf, func(rec record) error {
+		total++
+		if rec.Error != "" {
+			failed++
 		}
-		fmt.Println(urlStr, "=>", ctx.FindProxyForURL(urlStr, u.Hostname()))
+		profile.add(rec)
+		if conf.format != "json" && conf.format != "jsonl" {
+			// JSON以外では alert() のメッセージを標準エラー出力に出力する
+			logAlerts(os.Stderr, "", rec.Alerts)
+		}
+		if conf.format == "csv" || conf.format == "tsv" {
+			// CSVとTSVでは呼び出しと名前解決の記録を標準エラー出力に出力する
+			for _, tc := range rec.Trace {
+				fmt.Fprintf(os.Stderr, "%s: trace: %s\n", rec.URL, tc)
+			}
+			for _, l := range rec.Lookups {
+				fmt.Fprintf(os.Stderr, "%s: dns: %s\n", rec.URL, l)
+			}
+		}
+		return w.write(rec)
+	})
+
+	e := w.close()
+	if err == nil {
+		err = e
+	}
+	if err == nil && conf.coverage != nil {
+		err = writeCoverage(conf, os.Stderr)
+	}
+	if err == nil && conf.dnsProfile {
+		err = profile.write(os.Stderr)
+	}
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d of %d urls failed", failed, total)
+	}
+
+	return
+}
+
+// evaluateAll は全てのURLを最大 conf.jobs 個並行に評価し、入力の順番で fn に渡す
+func evaluateAll(ctx context.Context, p *pac.PAC, conf config, fn func(rec record) error) (err error) {
+	jobs := conf.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// pending は評価中の結果を入力の順番に並べる
+	// 容量で評価待ちの結果の数、sem で同時に評価するURLの数を制限する
+	pending := make(chan chan record, jobs)
+	sem := make(chan struct{}, jobs)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		var e error
+		for ch := range pending {
+			rec := <-ch
+			if e != nil {
+				continue
+			}
+			e = fn(rec)
+			if e != nil {
+				close(stop)
+			}
+		}
+		done <- e
+	}()
+
+	err = forEachURL(conf, func(urlStr string) error {
+		ch := make(chan record, 1)
+		select {
+		case pending <- ch:
+		case <-stop:
+			return errStopped
+		}
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			ch <- evaluate(ctx, p, urlStr)
+		}()
+		return nil
+	})
+	close(pending)
+
+	e := <-done
+	if err == nil || err == errStopped {
+		err = e
+	}
+	return
+}
+
+// logAlerts は alert() のメッセージをブラウザと同じ "PAC-alert: メッセージ" の形式で出力する
+func logAlerts(w io.Writer, prefix string, alerts []string) {
+	for _, msg := range alerts {
+		fmt.Fprintf(w, "%sPAC-alert: %s\n", prefix, msg)
+	}
+}
+
+// errStopped は出力に失敗して評価を中断したことを表す
+var errStopped = errors.New("stopped")
+
+// evaluate はURLに対してFindProxyForURLを評価した結果を返す
+// URLの誤りやスクリプトのエラーは rec.Error に記録する
+func evaluate(ctx context.Context, p *pac.PAC, urlStr string) (rec record) {
+	rec.URL = urlStr
+	defer func() {
+		// JSONで null ではなく [] と出力するため
+		if rec.Proxies == nil {
+			rec.Proxies = []pac.Proxy{}
+		}
+	}()
+
+	// alert() のメッセージをURLごとに記録する
+	ctx = pac.WithAlertHandler(ctx, func(msg string) {
+		rec.Alerts = append(rec.Alerts, msg)
+	})
+	// -trace を指定した場合は組み込み関数の呼び出しを記録する
+	ctx = pac.WithTraceHandler(ctx, func(c pac.Call) {
+		rec.Trace = append(rec.Trace, newTraceCall(c))
+	})
+	// -dns-profile を指定した場合は名前解決を記録する
+	ctx = pac.WithLookupHandler(ctx, func(l pac.Lookup) {
+		rec.Lookups = append(rec.Lookups, newDNSLookup(l))
+	})
+
+	start := time.Now()
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		rec.Error = err.Error()
+		return
+	}
+	rec.Host = u.Hostname()
+
+	r, e := p.FindProxyForURLHost(ctx, urlStr, rec.Host)
+	rec.setDuration(time.Since(start))
+	if e != nil {
+		// スクリプトのエラーは結果に記録する
+		rec.Error = e.Error()
+		if r == "" {
+			return
+		}
+	}
+	rec.Result = r
+
+	rec.Proxies, e = pac.ParseProxies(r)
+	if e != nil && rec.Error == "" {
+		rec.Error = e.Error()
 	}
 
 	return
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+func TestEvaluateAll(t *testing.T) {
+	p, err := pac.Load(strings.NewReader(`
+function FindProxyForURL(url, host) {
+    return "PROXY " + host + ":8080";
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := config{jobs: 8}
+	for i := 0; i < 100; i++ {
+		conf.urls = append(conf.urls, fmt.Sprintf("http://host%d/", i))
+	}
+
+	var got []string
+	err = evaluateAll(context.Background(), p, conf, func(rec record) error {
+		got = append(got, rec.Result)
+		return nil
+	})
+	if err != nil || len(got) != len(conf.urls) {
+		t.Fatalf("evaluateAll() = %v with %d results; want nil with %d results", err, len(got), len(conf.urls))
+	}
+	for i, r := range got {
+		if want := fmt.Sprintf("PROXY host%d:8080", i); r != want {
+			t.Errorf("result[%d] = %q; want %q", i, r, want)
+		}
+	}
+
+	// 出力に失敗したら評価を中断する
+	n := 0
+	err = evaluateAll(context.Background(), p, conf, func(rec record) error {
+		n++
+		return fmt.Errorf("write error")
+	})
+	if err == nil || err.Error() != "write error" || n != 1 {
+		t.Errorf("evaluateAll() = %v after %d results; want write error after 1 result", err, n)
+	}
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	p, err := pac.Load(strings.NewReader(`
+function FindProxyForURL(url, host) {
+    alert("host is " + host);
+    return "DIRECT";
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := evaluate(context.Background(), p, "http://hoge/")
+	if len(rec.Alerts) != 1 || rec.Alerts[0] != "host is hoge" {
+		t.Errorf("evaluate().Alerts = %q; want [host is hoge]", rec.Alerts)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	testUsageFmt = "%s [options] proxy.pac cases.yaml\n"
+)
+
+// testCase は回帰テストの1つのケース
+type testCase struct {
+	Name   string              `yaml:"name"`
+	URL    string              `yaml:"url"`
+	MyIP   string              `yaml:"my_ip"`
+	At     string              `yaml:"at"`
+	DNS    map[string]addrList `yaml:"dns"`
+	Expect string              `yaml:"expect"`
+}
+
+// addrList は1つまたは複数のIPアドレス
+// 文字列 ("10.1.2.3" または "10.1.2.3, 10.1.2.4") か配列で記述する
+type addrList []string
+
+func (al *addrList) UnmarshalYAML(value *yaml.Node) (err error) {
+	if value.Kind == yaml.ScalarNode {
+		*al = nil
+		for _, addr := range strings.Split(value.Value, ",") {
+			*al = append(*al, strings.TrimSpace(addr))
+		}
+		return
+	}
+	var a []string
+	err = value.Decode(&a)
+	*al = a
+	return
+}
+
+// readCases はYAMLまたはJSONで記述したテストケースの配列を読み込む
+// JSONはYAMLとして読み込む
+func readCases(data []byte) (cases []testCase, err error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(&cases)
+	if err != nil {
+		err = fmt.Errorf("abnormal test cases: %v", err)
+		return
+	}
+
+	for i := range cases {
+		c := &cases[i]
+		if c.Name == "" {
+			c.Name = c.URL
+		}
+		err = c.check()
+		if err != nil {
+			err = fmt.Errorf("case %d (%s): %v", i+1, c.Name, err)
+			return
+		}
+	}
+	return
+}
+
+// check はテストケースの記述を検査する
+func (c testCase) check() (err error) {
+	if c.URL == "" || c.Expect == "" {
+		err = fmt.Errorf("url and expect are required")
+		return
+	}
+	if c.MyIP != "" && net.ParseIP(c.MyIP) == nil {
+		err = fmt.Errorf("abnormal my_ip: %s", c.MyIP)
+		return
+	}
+	if c.At != "" {
+		_, err = time.Parse(time.RFC3339, c.At)
+		if err != nil {
+			err = fmt.Errorf("abnormal at: %s", c.At)
+			return
+		}
+	}
+	for host, addrs := range c.DNS {
+		for _, addr := range addrs {
+			if net.ParseIP(addr) == nil {
+				err = fmt.Errorf("abnormal address for %s: %s", host, addr)
+				return
+			}
+		}
+	}
+	return
+}
+
+// override はテストケースの条件で評価するために実行環境の設定を置き換える値を返す
+// DNSの記述にないホスト名は -hosts と -dns-server で指定したリゾルバ (指定がなければシステムのリゾルバ) で解決する
+func (c testCase) override(conf config) (o pac.Override) {
+	if c.MyIP != "" {
+		o.MyIPAddress = []string{c.MyIP}
+	}
+	if c.At != "" {
+		at, _ := time.Parse(time.RFC3339, c.At)
+		o.Clock = pac.FixedClock(at)
+	}
+	if len(c.DNS) > 0 {
+		hosts := pac.StaticResolver{}
+		for host, addrs := range c.DNS {
+			hosts[host] = addrs
+		}
+		var next pac.Resolver = net.DefaultResolver
+		if conf.resolver != nil {
+			next = conf.resolver
+		}
+		o.Resolver = pac.MultiResolver{hosts, next}
+	}
+	return
+}
+
+// caseResult はテストケースの実行結果
+type caseResult struct {
+	Case     testCase
+	Got      string
+	Error    string
+	Diff     []string
+	Duration time.Duration
+}
+
+func (cr caseResult) passed() bool {
+	return cr.Error == "" && cr.Diff == nil
+}
+
+// runCase はテストケースの条件でPACスクリプトを評価し、期待する結果と比較する
+func runCase(ctx context.Context, conf config, p *pac.PAC, c testCase) (r caseResult) {
+	r.Case = c
+	start := time.Now()
+	defer func() {
+		r.Duration = time.Since(start)
+	}()
+
+	var err error
+	r.Got, err = p.FindProxyForURL(pac.WithOverride(ctx, c.override(conf)), c.URL)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+
+	want, got := resultEntries(c.Expect), resultEntries(r.Got)
+	if strings.Join(want, "; ") != strings.Join(got, "; ") {
+		r.Diff = diffEntries(want, got)
+	}
+	return
+}
+
+// resultEntries はFindProxyForURLの戻り値を比較するためにエントリごとに正規化する
+// 解析できない場合は前後の空白を取り除いた戻り値をそのまま返す
+func resultEntries(s string) (r []string) {
+	proxies, err := pac.ParseProxies(s)
+	if err != nil {
+		r = []string{strings.TrimSpace(s)}
+		return
+	}
+	for _, proxy := range proxies {
+		r = append(r, proxy.String())
+	}
+	return
+}
+
+// diffEntries は期待するエントリと実際のエントリの差分を
+// "  共通", "- 期待するエントリのみ", "+ 実際のエントリのみ" の行で返す
+func diffEntries(want, got []string) (r []string) {
+	// 最長共通部分列の長さの表
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			r = append(r, "  "+want[i])
+			i++
+			j++
+		case j >= len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			r = append(r, "- "+want[i])
+			i++
+		default:
+			r = append(r, "+ "+got[j])
+			j++
+		}
+	}
+	return
+}
+
+// runTest はテストケースを実行し、結果を報告する
+func runTest(name string, args []string) (exitCode int) {
+	var conf config
+	var junitFile string
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), testUsageFmt, name)
+		flags.PrintDefaults()
+	}
+	conf.addPACFlags(flags)
+	flags.StringVar(&junitFile, "junit", "", "write a JUnit XML report to `file`")
+	conf.addCoverageFlags(flags)
+
+	err := flags.Parse(args)
+	if err == nil && flags.NArg() != 2 {
+		flags.Usage()
+		err = flag.ErrHelp
+	}
+	if err == nil {
+		err = conf.checkPACFlags()
+	}
+	if err == nil {
+		err = conf.checkCoverageFlags()
+	}
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		exitCode = argumentErr
+		return
+	}
+	conf.proxyPac = flags.Arg(0)
+
+	data, err := os.ReadFile(flags.Arg(1))
+	var cases []testCase
+	if err == nil {
+		cases, err = readCases(data)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = argumentErr
+		return
+	}
+
+	err = processTest(conf, cases, junitFile, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = runtimeErr
+	}
+	return
+}
+
+// processTest はPACスクリプトを読み込んで全てのテストケースを実行する
+func processTest(conf config, cases []testCase, junitFile string, w io.Writer) (err error) {
+	ctx := context.Background()
+
+	var p *pac.PAC
+	p, err = loadPAC(ctx, conf)
+	if err != nil {
+		return
+	}
+
+	results := make([]caseResult, len(cases))
+	failed := 0
+	for i, c := range cases {
+		results[i] = runCase(ctx, conf, p, c)
+		if !results[i].passed() {
+			failed++
+		}
+		writeCaseResult(w, results[i])
+	}
+
+	if junitFile != "" {
+		err = writeJUnitFile(junitFile, p.Name(), results)
+		if err != nil {
+			return
+		}
+	}
+
+	if conf.coverage != nil {
+		err = writeCoverage(conf, w)
+		if err != nil {
+			return
+		}
+	}
+
+	if failed > 0 {
+		err = fmt.Errorf("FAIL: %d of %d cases failed", failed, len(cases))
+		return
+	}
+	fmt.Fprintf(w, "ok: %d cases passed\n", len(cases))
+	return
+}
+
+// writeCaseResult はテストケースの結果を "--- PASS: 名前 (時間)" の形式で出力する
+func writeCaseResult(w io.Writer, r caseResult) {
+	status := "PASS"
+	if !r.passed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "--- %s: %s (%.3fms)\n", status, r.Case.Name, float64(r.Duration)/float64(time.Millisecond))
+	if r.passed() {
+		return
+	}
+
+	fmt.Fprintf(w, "    url:    %s\n", r.Case.URL)
+	fmt.Fprintf(w, "    expect: %s\n", r.Case.Expect)
+	if r.Error != "" {
+		fmt.Fprintf(w, "    error:  %s\n", r.Error)
+		return
+	}
+	fmt.Fprintf(w, "    got:    %s\n", r.Got)
+	for _, line := range r.Diff {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
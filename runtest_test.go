@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+const testCasesYAML = `
+- name: intranet goes direct
+  url: http://intra.foo.co.jp/
+  dns:
+    intra.foo.co.jp: 10.1.2.3
+  expect: DIRECT
+- url: http://hoge.com/
+  my_ip: 10.2.0.10
+  at: 2026-12-24T12:00:00+09:00
+  expect: PROXY osaka:8080;DIRECT
+- name: wrong expectation
+  url: http://hoge.com/
+  expect: PROXY osaka:8080; DIRECT
+`
+
+func TestReadCases(t *testing.T) {
+	cases, err := readCases([]byte(testCasesYAML))
+	if err != nil || len(cases) != 3 {
+		t.Fatalf("readCases() = %v, %v; want 3 cases, nil", cases, err)
+	}
+	if cases[1].Name != "http://hoge.com/" || cases[1].MyIP != "10.2.0.10" ||
+		!reflect.DeepEqual(cases[0].DNS["intra.foo.co.jp"], addrList{"10.1.2.3"}) {
+		t.Errorf("readCases() = %+v", cases)
+	}
+
+	// JSONでも記述できる
+	cases, err = readCases([]byte(`[{"url": "http://hoge/", "dns": {"hoge": ["10.0.0.1", "10.0.0.2"]}, "expect": "DIRECT"}]`))
+	if err != nil || len(cases) != 1 || !reflect.DeepEqual(cases[0].DNS["hoge"], addrList{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("readCases(json) = %+v, %v", cases, err)
+	}
+
+	for _, input := range []string{
+		"- url: http://hoge/\n",
+		"- url: http://hoge/\n  expect: DIRECT\n  my_ip: hoge\n",
+		"- url: http://hoge/\n  expect: DIRECT\n  at: tomorrow\n",
+		"- url: http://hoge/\n  expect: DIRECT\n  unknown: 1\n",
+		"- url: http://hoge/\n  expect: DIRECT\n  dns: {hoge: {a: 1}}\n",
+	} {
+		if _, err = readCases([]byte(input)); err == nil {
+			t.Errorf("readCases(%q) = _, nil; want !nil", input)
+		}
+	}
+}
+
+func TestDiffEntries(t *testing.T) {
+	want := []string{"- PROXY tokyo:8080", "+ PROXY osaka:8080", "  DIRECT"}
+	got := diffEntries([]string{"PROXY tokyo:8080", "DIRECT"}, []string{"PROXY osaka:8080", "DIRECT"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffEntries() = %q; want %q", got, want)
+	}
+}
+
+func TestProcessTest(t *testing.T) {
+	dir := t.TempDir()
+	pacFile := filepath.Join(dir, "proxy.pac")
+	err := os.WriteFile(pacFile, []byte(`
+function FindProxyForURL(url, host) {
+    if (isInNet(dnsResolve(host), "10.0.0.0", "255.0.0.0")) {
+        return "DIRECT";
+    }
+    if (isInNet(myIpAddress(), "10.2.0.0", "255.255.0.0") && timeRange(9, 18)) {
+        return "PROXY osaka:8080; DIRECT";
+    }
+    return "PROXY tokyo:8080; DIRECT";
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := readCases([]byte(testCasesYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	junitFile := filepath.Join(dir, "junit.xml")
+	// DNSの記述にないホスト名は固定のリゾルバで解決し、実際のDNSに問い合わせない
+	conf := config{proxyPac: pacFile, myIP: []string{"10.1.0.10"}, resolver: pac.StaticResolver{}}
+	err = processTest(conf, cases, junitFile, &buf)
+	if err == nil || err.Error() != "FAIL: 1 of 3 cases failed" {
+		t.Errorf("processTest() = %v; want FAIL: 1 of 3 cases failed", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "--- PASS: intranet goes direct") || !strings.Contains(out, "--- FAIL: wrong expectation") {
+		t.Errorf("processTest() output:\n%s", out)
+	}
+
+	bb, err := os.ReadFile(junitFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	err = xml.Unmarshal(bb, &report)
+	if err != nil || len(report.Suites) != 1 || report.Suites[0].Tests != 3 || report.Suites[0].Failures != 1 {
+		t.Errorf("junit report = %+v, %v; want 3 tests with 1 failure", report, err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+	"github.com/bunji2/findproxy/server"
+)
+
+const (
+	daemonUsageFmt = "%s [options] -pac proxy.pac\n"
+)
+
+// runServe はPACで選んだ上位のプロキシに転送するプロキシサーバを起動する
+func runServe(name string, args []string) (exitCode int) {
+	var conf config
+	flags := newDaemonFlags(name, &conf)
+	listen := flags.String("listen", ":3128", "`address` to listen on")
+	dialTimeout := flags.Duration("dial-timeout", 10*time.Second, "timeout for connecting to an upstream proxy or a destination")
+
+	exitCode = runDaemon(flags, &conf, args, func(rl *server.Reloader, logger *log.Logger) error {
+		fw := server.NewForwarder(rl, *dialTimeout, logger)
+		fw.Local = pacFileHandler(rl, http.NotFoundHandler())
+		logger.Printf("listening on %s", *listen)
+		return http.ListenAndServe(*listen, fw)
+	})
+	return
+}
+
+// runAPI はPACの評価結果をJSONで返すAPIサーバを起動する
+func runAPI(name string, args []string) (exitCode int) {
+	var conf config
+	flags := newDaemonFlags(name, &conf)
+	listen := flags.String("listen", ":8080", "`address` to listen on")
+
+	exitCode = runDaemon(flags, &conf, args, func(rl *server.Reloader, logger *log.Logger) error {
+		logger.Printf("listening on %s", *listen)
+		return http.ListenAndServe(*listen, pacFileHandler(rl, server.NewAPI(rl)))
+	})
+	return
+}
+
+// newDaemonFlags はデーモンとして動作するサブコマンドに共通のオプションを登録する
+func newDaemonFlags(name string, conf *config) (flags *flag.FlagSet) {
+	flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), daemonUsageFmt, name)
+		flags.PrintDefaults()
+	}
+	conf.addPACFlags(flags)
+	flags.StringVar(&conf.proxyPac, "pac", "", "proxy.pac `file` or http(s) URL")
+	flags.DurationVar(&conf.reloadInterval, "reload-interval", 5*time.Second, "`interval` to check proxy.pac for changes and reload it (0 to disable)")
+	return
+}
+
+// pacFileHandler は /proxy.pac と /wpad.dat で読み込んだPACファイルを返し、
+// それ以外のリクエストを handler で処理する
+func pacFileHandler(rl *server.Reloader, handler http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/proxy.pac", rl)
+	mux.Handle("/wpad.dat", rl)
+	mux.Handle("/", handler)
+	return mux
+}
+
+// runDaemon はオプションを解析してPACスクリプトを読み込み、serve を実行する
+func runDaemon(flags *flag.FlagSet, conf *config, args []string, serve func(rl *server.Reloader, logger *log.Logger) error) (exitCode int) {
+	err := flags.Parse(args)
+	if err == nil && flags.NArg() > 0 {
+		err = fmt.Errorf("unexpected argument: %s", flags.Arg(0))
+	}
+	if err == nil && conf.proxyPac == "" && !conf.wpad {
+		err = fmt.Errorf("-pac or -wpad is required")
+	}
+	if err == nil {
+		err = conf.checkPACFlags()
+	}
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		exitCode = argumentErr
+		return
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	conf.alertLogger = logger
+
+	ctx := context.Background()
+	p, err := loadPAC(ctx, *conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = runtimeErr
+		return
+	}
+
+	// 読み込んだPACファイルの変更を監視し、変更されたらコンパイルし直す
+	rl := server.NewReloader(p, func(ctx context.Context) ([]byte, error) {
+		return readPAC(ctx, *conf, p.Name())
+	}, func(src []byte) (*pac.PAC, error) {
+		return pac.Compile(p.Name(), src, conf.pacOptions()...)
+	}, logger)
+	if conf.reloadInterval > 0 {
+		go rl.Watch(ctx, conf.reloadInterval)
+	}
+
+	err = serve(rl, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = runtimeErr
+	}
+	return
+}
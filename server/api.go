@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// maxBatchURLs は POST /batch で一度に評価できるURLの最大数
+const maxBatchURLs = 10000
+
+// maxBatchBody は POST /batch のリクエストボディの最大サイズ
+const maxBatchBody = 16 * 1024 * 1024
+
+// Result はAPIが返す1つのURLの評価結果
+type Result struct {
+	URL     string      `json:"url"`
+	Host    string      `json:"host"`
+	Result  string      `json:"result"`
+	Proxies []pac.Proxy `json:"proxies"`
+	Error   string      `json:"error,omitempty"`
+	Alerts  []string    `json:"alerts,omitempty"`
+}
+
+// BatchRequest は POST /batch のリクエスト
+type BatchRequest struct {
+	URLs []string `json:"urls"`
+}
+
+// BatchResponse は POST /batch の応答
+type BatchResponse struct {
+	Results []Result `json:"results"`
+}
+
+// errorResponse はリクエストの誤りを表す応答
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// API はPACの評価結果をJSONで返すHTTPのAPI
+//
+//	GET  /findproxy?url=...             => Result
+//	POST /batch {"urls": ["...", ...]}  => {"results": [Result, ...]}
+type API struct {
+	Finder Finder
+	mux    *http.ServeMux
+}
+
+// NewAPI は API を生成する
+func NewAPI(finder Finder) (r *API) {
+	r = &API{
+		Finder: finder,
+		mux:    http.NewServeMux(),
+	}
+	r.mux.HandleFunc("/findproxy", r.handleFindProxy)
+	r.mux.HandleFunc("/batch", r.handleBatch)
+	return
+}
+
+// ServeHTTP はAPIへのリクエストを処理する
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mux.ServeHTTP(w, r)
+}
+
+func (api *API) handleFindProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"method not allowed"})
+		return
+	}
+	rawurl := r.URL.Query().Get("url")
+	if rawurl == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"missing url parameter"})
+		return
+	}
+	if _, err := url.Parse(rawurl); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, api.evaluate(r.Context(), rawurl))
+}
+
+func (api *API) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"method not allowed"})
+		return
+	}
+
+	var req BatchRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{"abnormal request: " + err.Error()})
+		return
+	}
+	if len(req.URLs) > maxBatchURLs {
+		writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{"too many urls"})
+		return
+	}
+
+	resp := BatchResponse{Results: make([]Result, len(req.URLs))}
+	for i, rawurl := range req.URLs {
+		if r.Context().Err() != nil {
+			return
+		}
+		resp.Results[i] = api.evaluate(r.Context(), rawurl)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// evaluate はURLに対してFindProxyForURLを評価した結果を返す
+func (api *API) evaluate(ctx context.Context, rawurl string) (r Result) {
+	r.URL = rawurl
+	r.Proxies = []pac.Proxy{}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	r.Host = u.Hostname()
+
+	// alert() のメッセージを結果に含める
+	ctx = pac.WithAlertHandler(ctx, func(msg string) {
+		r.Alerts = append(r.Alerts, msg)
+	})
+	r.Result, err = api.Finder.FindProxyForURL(ctx, rawurl)
+	if err != nil {
+		r.Error = err.Error()
+		if r.Result == "" {
+			return
+		}
+	}
+
+	proxies, err := pac.ParseProxies(r.Result)
+	if proxies != nil {
+		r.Proxies = proxies
+	}
+	if err != nil && r.Error == "" {
+		r.Error = err.Error()
+	}
+	return
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// hostFinder はホスト名によって結果を変える Finder
+type hostFinder struct{}
+
+func (hostFinder) FindProxyForURL(ctx context.Context, rawurl string) (string, error) {
+	u, _ := url.Parse(rawurl)
+	switch u.Hostname() {
+	case "hoge.com":
+		return "PROXY proxy2:8080; DIRECT", nil
+	case "broken":
+		return "", fmt.Errorf("ReferenceError: 'foo' is not defined")
+	case "fallback":
+		return "DIRECT", fmt.Errorf("ReferenceError: 'foo' is not defined")
+	}
+	return "DIRECT", nil
+}
+
+func TestAPIFindProxy(t *testing.T) {
+	ts := httptest.NewServer(NewAPI(hostFinder{}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/findproxy?url=" + url.QueryEscape("http://hoge.com/hoge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var r Result
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || r.Host != "hoge.com" || r.Result != "PROXY proxy2:8080; DIRECT" ||
+		len(r.Proxies) != 2 || r.Proxies[0].Host != "proxy2" || r.Error != "" {
+		t.Errorf("GET /findproxy = %d %+v; want 200 PROXY proxy2:8080; DIRECT", resp.StatusCode, r)
+	}
+
+	for _, query := range []string{"", "?url=%25zz"} {
+		resp, err = http.Get(ts.URL + "/findproxy" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /findproxy%s = %d; want 400", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	ts := httptest.NewServer(NewAPI(hostFinder{}))
+	defer ts.Close()
+
+	body := `{"urls": ["http://hoge.com/hoge", "http://hogehoge/", "http://broken/", "http://fallback/"]}`
+	resp, err := http.Post(ts.URL+"/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var r BatchResponse
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || len(r.Results) != 4 {
+		t.Fatalf("POST /batch = %d %+v; want 200 with 4 results", resp.StatusCode, r)
+	}
+	if r.Results[0].Result != "PROXY proxy2:8080; DIRECT" || r.Results[1].Result != "DIRECT" {
+		t.Errorf("POST /batch = %+v; want PROXY proxy2:8080; DIRECT, DIRECT", r.Results)
+	}
+	if r.Results[2].Error == "" {
+		t.Errorf("POST /batch: results[2].error = \"\"; want !\"\"")
+	}
+	if fb := r.Results[3]; fb.Result != "DIRECT" || len(fb.Proxies) != 1 || fb.Error == "" {
+		t.Errorf("POST /batch: results[3] = %+v; want DIRECT with error", fb)
+	}
+
+	resp, err = http.Post(ts.URL+"/batch", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /batch {  = %d; want 400", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /batch = %d; want 405", resp.StatusCode)
+	}
+}
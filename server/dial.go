@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// dialVia は proxy を経由して addr ("host:port") に接続する
+func dialVia(ctx context.Context, d *net.Dialer, proxy pac.Proxy, addr string) (conn net.Conn, err error) {
+	if proxy.Type == pac.TypeDirect {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+		return
+	}
+
+	conn, err = d.DialContext(ctx, "tcp", proxy.Addr())
+	if err != nil {
+		return
+	}
+
+	// ハンドシェイクも接続のタイムアウトに含める
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	switch proxy.Type {
+	case pac.TypeProxy, pac.TypeHTTP:
+		err = connectHTTP(conn, addr)
+	case pac.TypeHTTPS:
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Host})
+		conn = tlsConn
+		err = tlsConn.HandshakeContext(ctx)
+		if err == nil {
+			err = connectHTTP(conn, addr)
+		}
+	case pac.TypeSOCKS, pac.TypeSOCKS4:
+		err = connectSOCKS4(conn, addr)
+	case pac.TypeSOCKS5:
+		err = connectSOCKS5(conn, addr)
+	default:
+		err = fmt.Errorf("unsupported proxy type: %s", proxy.Type)
+	}
+
+	if err != nil {
+		conn.Close()
+		conn = nil
+		err = fmt.Errorf("%s: %v", proxy, err)
+		return
+	}
+	conn.SetDeadline(noDeadline)
+	return
+}
+
+// connectHTTP はHTTPプロキシにCONNECTでトンネルを要求する
+func connectHTTP(conn net.Conn, addr string) (err error) {
+	_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", addr, addr)
+	if err != nil {
+		return
+	}
+
+	// トンネルのデータを読み込み過ぎないように1バイトずつ読む
+	var resp *http.Response
+	resp, err = http.ReadResponse(bufio.NewReaderSize(byteReader{conn}, 1), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	return
+}
+
+// byteReader は1バイトずつ読み込むリーダ
+type byteReader struct {
+	r io.Reader
+}
+
+func (br byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return br.r.Read(p)
+}
+
+// connectSOCKS4 はSOCKS4aで接続を要求する
+func connectSOCKS4(conn net.Conn, addr string) (err error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return
+	}
+
+	req := []byte{4, 1, byte(port >> 8), byte(port)}
+	ip := net.ParseIP(host).To4()
+	if ip != nil {
+		req = append(req, ip...)
+		req = append(req, 0)
+	} else {
+		// SOCKS4a: 0.0.0.x の後にホスト名を送る
+		req = append(req, 0, 0, 0, 1, 0)
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+	_, err = conn.Write(req)
+	if err != nil {
+		return
+	}
+
+	resp := make([]byte, 8)
+	_, err = io.ReadFull(conn, resp)
+	if err != nil {
+		return
+	}
+	if resp[1] != 0x5a {
+		err = fmt.Errorf("socks4: request rejected: %d", resp[1])
+	}
+	return
+}
+
+// connectSOCKS5 は認証なしのSOCKS5で接続を要求する
+func connectSOCKS5(conn net.Conn, addr string) (err error) {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return
+	}
+
+	_, err = conn.Write([]byte{5, 1, 0})
+	if err != nil {
+		return
+	}
+	resp := make([]byte, 2)
+	_, err = io.ReadFull(conn, resp)
+	if err != nil {
+		return
+	}
+	if resp[0] != 5 || resp[1] != 0 {
+		err = fmt.Errorf("socks5: no acceptable authentication method")
+		return
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(req, 1)
+		req = append(req, ip.To4()...)
+	} else if ip != nil {
+		req = append(req, 4)
+		req = append(req, ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			err = fmt.Errorf("socks5: too long host name")
+			return
+		}
+		req = append(req, 3, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	_, err = conn.Write(req)
+	if err != nil {
+		return
+	}
+
+	head := make([]byte, 4)
+	_, err = io.ReadFull(conn, head)
+	if err != nil {
+		return
+	}
+	if head[1] != 0 {
+		err = fmt.Errorf("socks5: request failed: %d", head[1])
+		return
+	}
+
+	// 残りの BND.ADDR と BND.PORT を読み捨てる
+	var n int
+	switch head[3] {
+	case 1:
+		n = net.IPv4len
+	case 4:
+		n = net.IPv6len
+	case 3:
+		l := make([]byte, 1)
+		_, err = io.ReadFull(conn, l)
+		if err != nil {
+			return
+		}
+		n = int(l[0])
+	default:
+		err = fmt.Errorf("socks5: abnormal address type: %d", head[3])
+		return
+	}
+	_, err = io.ReadFull(conn, make([]byte, n+2))
+	return
+}
+
+func splitHostPort(addr string) (host string, port int, err error) {
+	var portStr string
+	host, portStr, err = net.SplitHostPort(addr)
+	if err != nil {
+		return
+	}
+	port, err = strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		err = fmt.Errorf("abnormal port: %s", portStr)
+	}
+	return
+}
@@ -0,0 +1,254 @@
+// Package server はPACスクリプトによるプロキシの選択をネットワークのサービスとして提供するパッケージ
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// noDeadline はデッドラインの解除に使用する
+var noDeadline time.Time
+
+// Finder はURLへの接続に使用すべきプロキシを返すインターフェース
+// 並行に呼び出されるため、実装は並行に呼び出せなければならない
+// *pac.PAC はこのインターフェースを満たす
+type Finder interface {
+	FindProxyForURL(ctx context.Context, rawurl string) (string, error)
+}
+
+// Forwarder はリクエストごとにPACで上位のプロキシを選んで転送するHTTPプロキシ
+// 接続できない場合は FindProxyForURL の戻り値の次のエントリを試す
+type Forwarder struct {
+	Finder Finder
+
+	// DialTimeout は上位のプロキシまたは接続先への接続のタイムアウト
+	DialTimeout time.Duration
+
+	// Logger はリクエストごとの転送先を記録する (nil の場合は記録しない)
+	Logger *log.Logger
+
+	// Local はプロキシ宛てでないリクエスト (GET /proxy.pac など) を処理する
+	// nil の場合はエラーを返す
+	Local http.Handler
+
+	mu         sync.Mutex
+	reverse    *httputil.ReverseProxy
+	transports map[pac.Proxy]*http.Transport
+}
+
+// NewForwarder は Forwarder を生成する
+func NewForwarder(finder Finder, dialTimeout time.Duration, logger *log.Logger) (r *Forwarder) {
+	r = &Forwarder{
+		Finder:      finder,
+		DialTimeout: dialTimeout,
+		Logger:      logger,
+		transports:  map[pac.Proxy]*http.Transport{},
+	}
+	r.reverse = &httputil.ReverseProxy{
+		Director:  func(*http.Request) {},
+		Transport: roundTripper{r},
+		ErrorLog:  logger,
+	}
+	return
+}
+
+func (fw *Forwarder) logf(format string, v ...interface{}) {
+	if fw.Logger != nil {
+		fw.Logger.Printf(format, v...)
+	}
+}
+
+// ServeHTTP はプロキシへのリクエストを処理する
+func (fw *Forwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		fw.serveConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		if fw.Local != nil {
+			fw.Local.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, "this is a proxy server; request must use an absolute URL", http.StatusBadRequest)
+		return
+	}
+	fw.reverse.ServeHTTP(w, r)
+}
+
+// proxies はURLに対してPACで選んだプロキシを返す
+func (fw *Forwarder) proxies(ctx context.Context, rawurl string) (r []pac.Proxy, err error) {
+	var s string
+	s, err = fw.Finder.FindProxyForURL(ctx, rawurl)
+	if err != nil {
+		if s == "" {
+			return
+		}
+		// フォールバックの結果を使用する
+		fw.logf("%s: %v (falling back to %s)", rawurl, err, s)
+	}
+	r, err = pac.ParseProxies(s)
+	if len(r) > 0 {
+		// 解析できたエントリだけを使用する
+		err = nil
+	}
+	return
+}
+
+// serveConnect はCONNECTのトンネルを上位のプロキシ経由で中継する
+func (fw *Forwarder) serveConnect(w http.ResponseWriter, r *http.Request) {
+	addr := r.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+
+	// ブラウザと同じくHTTPSのURLはパスを除いてPACに渡す
+	host, port, _ := net.SplitHostPort(addr)
+	u := &url.URL{Scheme: "https", Host: addr, Path: "/"}
+	if port == "443" {
+		u.Host = host
+	}
+
+	proxies, err := fw.proxies(r.Context(), u.String())
+	if err != nil {
+		fw.logf("CONNECT %s: %v", addr, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var upstream net.Conn
+	for _, proxy := range proxies {
+		upstream, err = fw.dial(r.Context(), proxy, addr)
+		if err == nil {
+			fw.logf("CONNECT %s via %s", addr, proxy)
+			break
+		}
+		fw.logf("CONNECT %s via %s: %v", addr, proxy, err)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	_, err = io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n")
+	if err != nil {
+		return
+	}
+
+	// クライアントが応答を待たずに送ったデータも転送する
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, rw.Reader)
+		closeWrite(upstream)
+		close(done)
+	}()
+	io.Copy(client, upstream)
+	closeWrite(client)
+	<-done
+}
+
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	} else {
+		conn.Close()
+	}
+}
+
+func (fw *Forwarder) dial(ctx context.Context, proxy pac.Proxy, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: fw.DialTimeout}
+	if fw.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fw.DialTimeout)
+		defer cancel()
+	}
+	return dialVia(ctx, d, proxy, addr)
+}
+
+// transport はプロキシごとの http.Transport を返す
+// HTTPプロキシにはリクエストをそのまま転送し、それ以外は dialVia で接続する
+func (fw *Forwarder) transport(proxy pac.Proxy) (t *http.Transport) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	t = fw.transports[proxy]
+	if t != nil {
+		return
+	}
+
+	t = &http.Transport{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	switch proxy.Type {
+	case pac.TypeProxy, pac.TypeHTTP:
+		t.Proxy = http.ProxyURL(&url.URL{Scheme: "http", Host: proxy.Addr()})
+		t.DialContext = (&net.Dialer{Timeout: fw.DialTimeout}).DialContext
+	case pac.TypeHTTPS:
+		t.Proxy = http.ProxyURL(&url.URL{Scheme: "https", Host: proxy.Addr()})
+		t.DialContext = (&net.Dialer{Timeout: fw.DialTimeout}).DialContext
+	default:
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fw.dial(ctx, proxy, addr)
+		}
+	}
+	fw.transports[proxy] = t
+	return
+}
+
+// roundTripper はPACで選んだプロキシを順番に試して転送する
+type roundTripper struct {
+	fw *Forwarder
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	var proxies []pac.Proxy
+	proxies, err = rt.fw.proxies(req.Context(), req.URL.String())
+	if err != nil {
+		err = fmt.Errorf("FindProxyForURL: %v", err)
+		rt.fw.logf("%s %s: %v", req.Method, req.URL, err)
+		return
+	}
+
+	// ボディを送り直せないリクエストは最初のエントリだけを試す
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for i, proxy := range proxies {
+		if i > 0 && req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return
+			}
+		}
+		resp, err = rt.fw.transport(proxy).RoundTrip(req)
+		if err == nil {
+			rt.fw.logf("%s %s via %s", req.Method, req.URL, proxy)
+			return
+		}
+		rt.fw.logf("%s %s via %s: %v", req.Method, req.URL, proxy, err)
+		if !replayable || req.Context().Err() != nil {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fixedFinder は常に同じ結果を返す Finder
+type fixedFinder string
+
+func (f fixedFinder) FindProxyForURL(ctx context.Context, rawurl string) (string, error) {
+	return string(f), nil
+}
+
+func newTarget(t *testing.T, tls bool) (ts *httptest.Server) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	})
+	if tls {
+		ts = httptest.NewTLSServer(handler)
+	} else {
+		ts = httptest.NewServer(handler)
+	}
+	t.Cleanup(ts.Close)
+	return
+}
+
+// newForwarder は Forwarder を起動し、そのアドレスを返す
+func newForwarder(t *testing.T, result string) (addr string) {
+	ps := httptest.NewServer(NewForwarder(fixedFinder(result), time.Second, nil))
+	t.Cleanup(ps.Close)
+	addr = ps.Listener.Addr().String()
+	return
+}
+
+func get(t *testing.T, proxyAddr, target string, tlsConfig *tls.Config) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(&url.URL{Scheme: "http", Host: proxyAddr}),
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: 5 * time.Second,
+	}
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatalf("Get(%s) = _, %v; want nil", target, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("Get(%s) = %q; want \"hello\"", target, body)
+	}
+}
+
+// closedAddr は接続できないアドレスを返す
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestForwarderFallback(t *testing.T) {
+	target := newTarget(t, false)
+	addr := newForwarder(t, "PROXY "+closedAddr(t)+"; DIRECT")
+	get(t, addr, target.URL+"/hoge", nil)
+}
+
+func TestForwarderChain(t *testing.T) {
+	// クライアント => PROXY (Forwarder) => DIRECT (Forwarder) => 接続先
+	target := newTarget(t, false)
+	direct := newForwarder(t, "DIRECT")
+	addr := newForwarder(t, "PROXY "+direct)
+	get(t, addr, target.URL+"/hoge", nil)
+
+	tlsTarget := newTarget(t, true)
+	tlsConfig := tlsTarget.Client().Transport.(*http.Transport).TLSClientConfig
+	get(t, addr, tlsTarget.URL+"/hoge", tlsConfig)
+}
+
+func TestForwarderSOCKS5(t *testing.T) {
+	target := newTarget(t, false)
+	addr := newForwarder(t, "SOCKS5 "+fakeSOCKS5(t))
+	get(t, addr, target.URL+"/hoge", nil)
+}
+
+// fakeSOCKS5 は認証なしのCONNECTだけを受け付けるSOCKS5サーバを起動する
+func fakeSOCKS5(t *testing.T) (addr string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 262)
+				if _, err := io.ReadFull(conn, buf[:3]); err != nil {
+					return
+				}
+				conn.Write([]byte{5, 0})
+				if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+					return
+				}
+				var host string
+				switch buf[3] {
+				case 1:
+					if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+						return
+					}
+					host = net.IP(buf[:4]).String()
+				case 3:
+					if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+						return
+					}
+					n := int(buf[0])
+					if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+						return
+					}
+					host = string(buf[:n])
+				default:
+					return
+				}
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					return
+				}
+				port := binary.BigEndian.Uint16(buf[:2])
+				dest, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer dest.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go io.Copy(dest, conn)
+				io.Copy(conn, dest)
+			}()
+		}
+	}()
+
+	addr = l.Addr().String()
+	return
+}
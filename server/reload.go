@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+// Reloader は読み込んだPACスクリプトを保持し、ソースが変更されたらコンパイルし直して差し替える
+// コンパイルに失敗した場合は以前のPACスクリプトを使い続ける
+type Reloader struct {
+	// Read はPACスクリプトのソースを読み込む
+	Read func(ctx context.Context) ([]byte, error)
+
+	// Compile はPACスクリプトのソースをコンパイルする
+	Compile func(src []byte) (*pac.PAC, error)
+
+	// Logger は読み込み直した結果を記録する (nil の場合は記録しない)
+	Logger *log.Logger
+
+	current atomic.Pointer[pac.PAC]
+}
+
+// NewReloader は読み込み済みのPACスクリプトで Reloader を生成する
+func NewReloader(p *pac.PAC, read func(ctx context.Context) ([]byte, error), compile func(src []byte) (*pac.PAC, error), logger *log.Logger) (r *Reloader) {
+	r = &Reloader{
+		Read:    read,
+		Compile: compile,
+		Logger:  logger,
+	}
+	r.current.Store(p)
+	return
+}
+
+// Current は現在のPACスクリプトを返す
+func (rl *Reloader) Current() *pac.PAC {
+	return rl.current.Load()
+}
+
+// Reload はソースを読み込み、変更されていればコンパイルし直して差し替える
+// changed は差し替えたかどうか
+func (rl *Reloader) Reload(ctx context.Context) (changed bool, err error) {
+	var src []byte
+	src, err = rl.Read(ctx)
+	if err != nil {
+		return
+	}
+	if bytes.Equal(src, rl.Current().Source()) {
+		return
+	}
+
+	var p *pac.PAC
+	p, err = rl.Compile(src)
+	if err != nil {
+		return
+	}
+	rl.current.Store(p)
+	changed = true
+	return
+}
+
+// Watch は ctx が終了するまで interval ごとに Reload を呼び出す
+func (rl *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := rl.Reload(ctx)
+		if rl.Logger == nil {
+			continue
+		}
+		if err != nil {
+			rl.Logger.Printf("reload %s: %v (keeping the previous script)", rl.Current().Name(), err)
+		} else if changed {
+			rl.Logger.Printf("reloaded %s", rl.Current().Name())
+		}
+	}
+}
+
+// FindProxyForURL は現在のPACスクリプトで使用すべきプロキシを返す
+func (rl *Reloader) FindProxyForURL(ctx context.Context, rawurl string) (string, error) {
+	return rl.Current().FindProxyForURL(ctx, rawurl)
+}
+
+// ServeHTTP は現在のPACスクリプトのソースをPACファイルのMIMEタイプで返す
+func (rl *Reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	src := rl.Current().Source()
+	w.Header().Set("Content-Type", pac.MIMEType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(src)))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(src))
+}
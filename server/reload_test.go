@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+func TestReloader(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "proxy.pac")
+	write := func(src string) {
+		if err := os.WriteFile(filePath, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`function FindProxyForURL(url, host) { return "PROXY v1:8080"; }`)
+
+	p, err := pac.LoadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewReloader(p, func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(filePath)
+	}, func(src []byte) (*pac.PAC, error) {
+		return pac.Compile(filePath, src)
+	}, nil)
+
+	ctx := context.Background()
+	check := func(want string) {
+		t.Helper()
+		got, err := rl.FindProxyForURL(ctx, "http://hoge.com/")
+		if err != nil || got != want {
+			t.Errorf("FindProxyForURL() = %v, %v; want %v, nil", got, err, want)
+		}
+	}
+
+	if changed, err := rl.Reload(ctx); changed || err != nil {
+		t.Errorf("Reload() without change = %v, %v; want false, nil", changed, err)
+	}
+	check("PROXY v1:8080")
+
+	write(`function FindProxyForURL(url, host) { return "PROXY v2:8080"; }`)
+	if changed, err := rl.Reload(ctx); !changed || err != nil {
+		t.Errorf("Reload() = %v, %v; want true, nil", changed, err)
+	}
+	check("PROXY v2:8080")
+
+	// コンパイルできない場合は以前のスクリプトを使い続ける
+	write(`function FindProxyForURL(url, host) { return "PROXY v3:8080";`)
+	if changed, err := rl.Reload(ctx); changed || err == nil {
+		t.Errorf("Reload() with syntax error = %v, %v; want false, !nil", changed, err)
+	}
+	check("PROXY v2:8080")
+
+	ts := httptest.NewServer(rl)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/wpad.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != pac.MIMEType || string(body) != string(rl.Current().Source()) {
+		t.Errorf("GET /wpad.dat = %s %q; want %s and the current script", resp.Header.Get("Content-Type"), body, pac.MIMEType)
+	}
+}
@@ -0,0 +1,339 @@
+package wpad
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// DHCPのメッセージとオプション
+const (
+	dhcpInform = 8 // DHCPINFORM
+	dhcpAck    = 5 // DHCPACK
+
+	optPad          = 0
+	optMessageType  = 53
+	optParamRequest = 55
+	optWPAD         = 252 // WPADのURL
+	optEnd          = 255
+
+	bootRequest = 1
+	bootReply   = 2
+)
+
+// dhcpHeaderLen はマジッククッキーまでのBOOTPヘッダの長さ
+const dhcpHeaderLen = 236
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// DefaultDHCPServer はDHCPINFORMを送信する既定の宛先
+const DefaultDHCPServer = "255.255.255.255:67"
+
+// DHCPClient はDHCPINFORMでWPADのURL (オプション252) を問い合わせる
+type DHCPClient struct {
+	// Interface はDHCPINFORMを送信するネットワークインターフェース名
+	// 空の場合は LocalAddr のアドレス、それもなければ既定の経路のインターフェースを使用する
+	// (RFC 2131 ではDHCPINFORMの ciaddr はクライアントのアドレスでなければならない)
+	Interface string
+
+	// Server はDHCPINFORMの宛先 (既定は DefaultDHCPServer)
+	Server string
+
+	// LocalAddr は送信元のアドレス
+	// 空の場合はインターフェースのIPv4アドレスの68番ポートを使用する (root 権限が必要)
+	LocalAddr string
+
+	// Timeout は応答を待つ時間 (既定は3秒)
+	Timeout time.Duration
+}
+
+// Inform はDHCPINFORMを送信し、DHCPACKに含まれるWPADのURLを返す
+func (c *DHCPClient) Inform(ctx context.Context) (wpadURL string, err error) {
+	var ciaddr net.IP
+	var mac net.HardwareAddr
+	ciaddr, mac, err = c.clientAddr()
+	if err != nil {
+		return
+	}
+
+	localAddr := c.LocalAddr
+	if localAddr == "" {
+		localAddr = net.JoinHostPort(ciaddr.String(), "68")
+	}
+	server := c.Server
+	if server == "" {
+		server = DefaultDHCPServer
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+
+	var serverAddr *net.UDPAddr
+	serverAddr, err = net.ResolveUDPAddr("udp4", server)
+	if err != nil {
+		return
+	}
+
+	var lc net.ListenConfig
+	var conn net.PacketConn
+	conn, err = lc.ListenPacket(ctx, "udp4", localAddr)
+	if err != nil {
+		err = bindError(localAddr, err)
+		return
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	var xid [4]byte
+	_, err = rand.Read(xid[:])
+	if err != nil {
+		return
+	}
+
+	_, err = conn.WriteTo(newInform(binary.BigEndian.Uint32(xid[:]), ciaddr, mac), serverAddr)
+	if err != nil {
+		return
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		var n int
+		n, _, err = conn.ReadFrom(buf)
+		if err != nil {
+			err = fmt.Errorf("dhcp: no DHCPACK: %v", err)
+			return
+		}
+		var ok bool
+		wpadURL, ok, err = parseAck(buf[:n], binary.BigEndian.Uint32(xid[:]))
+		if ok {
+			return
+		}
+		// 他のクライアント宛ての応答は読み飛ばす
+	}
+}
+
+// bindError は送信元のアドレスに割り当てられなかったエラーを、権限がない場合は理由がわかるようにして返す
+func bindError(localAddr string, err error) error {
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("dhcp: binding %s is not permitted (DHCP clients use port 68, which needs root or CAP_NET_BIND_SERVICE; a lease file can be read instead): %v", localAddr, err)
+	}
+	return err
+}
+
+// clientAddr はDHCPINFORMの ciaddr とするクライアントのIPv4アドレスとMACアドレスを返す
+func (c *DHCPClient) clientAddr() (ip net.IP, mac net.HardwareAddr, err error) {
+	if c.Interface != "" {
+		ip, mac, err = interfaceAddr(c.Interface)
+		return
+	}
+
+	if c.LocalAddr != "" {
+		var addr *net.UDPAddr
+		addr, err = net.ResolveUDPAddr("udp4", c.LocalAddr)
+		if err != nil {
+			return
+		}
+		ip = addr.IP.To4()
+	}
+	if ip == nil || ip.IsUnspecified() {
+		ip, err = defaultRouteAddr()
+		if err != nil {
+			return
+		}
+	}
+	mac = interfaceMAC(ip)
+	return
+}
+
+// probeAddr は既定の経路を調べるために経路を引く宛先 (文書用のアドレス)
+// UDPは接続しても送信しないため、実際に通信は発生しない
+const probeAddr = "192.0.2.1:67"
+
+// defaultRouteAddr は既定の経路で使われるIPv4アドレスを返す
+func defaultRouteAddr() (ip net.IP, err error) {
+	var conn net.Conn
+	conn, err = net.Dial("udp4", probeAddr)
+	if err != nil {
+		err = fmt.Errorf("dhcp: no default route (give the interface): %v", err)
+		return
+	}
+	defer conn.Close()
+	ip = conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	return
+}
+
+// interfaceMAC はIPv4アドレスを持つネットワークインターフェースのMACアドレスを返す
+// 見つからない場合は nil を返す
+func interfaceMAC(ip net.IP) (mac net.HardwareAddr) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	for _, ifi := range ifis {
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				mac = ifi.HardwareAddr
+				return
+			}
+		}
+	}
+	return
+}
+
+// interfaceAddr はネットワークインターフェースのIPv4アドレスとMACアドレスを返す
+func interfaceAddr(name string) (ip net.IP, mac net.HardwareAddr, err error) {
+	var ifi *net.Interface
+	ifi, err = net.InterfaceByName(name)
+	if err != nil {
+		return
+	}
+	mac = ifi.HardwareAddr
+
+	var addrs []net.Addr
+	addrs, err = ifi.Addrs()
+	if err != nil {
+		return
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			ip = ipNet.IP.To4()
+			return
+		}
+	}
+	err = fmt.Errorf("dhcp: %s has no IPv4 address", name)
+	return
+}
+
+// newInform はWPADのURLを要求するDHCPINFORMを生成する
+func newInform(xid uint32, ciaddr net.IP, mac net.HardwareAddr) (r []byte) {
+	r = make([]byte, dhcpHeaderLen, dhcpHeaderLen+16)
+	r[0] = bootRequest
+	r[1] = 1 // Ethernet
+	r[2] = 6
+	binary.BigEndian.PutUint32(r[4:8], xid)
+	copy(r[12:16], ciaddr.To4())
+	copy(r[28:44], mac)
+
+	r = append(r, magicCookie...)
+	r = append(r, optMessageType, 1, dhcpInform)
+	r = append(r, optParamRequest, 1, optWPAD)
+	r = append(r, optEnd)
+	return
+}
+
+// parseAck はDHCPの応答からWPADのURLを取り出す
+// ok はトランザクションIDが一致するDHCPACKかどうか
+func parseAck(b []byte, xid uint32) (wpadURL string, ok bool, err error) {
+	if len(b) < dhcpHeaderLen+len(magicCookie) || b[0] != bootReply ||
+		binary.BigEndian.Uint32(b[4:8]) != xid || string(b[dhcpHeaderLen:dhcpHeaderLen+4]) != string(magicCookie) {
+		return
+	}
+
+	var msgType byte
+	opts := b[dhcpHeaderLen+len(magicCookie):]
+	for len(opts) > 0 {
+		code := opts[0]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || len(opts) < 2+int(opts[1]) {
+			err = fmt.Errorf("dhcp: truncated option %d", code)
+			return
+		}
+		value := opts[2 : 2+int(opts[1])]
+		switch code {
+		case optMessageType:
+			if len(value) == 1 {
+				msgType = value[0]
+			}
+		case optWPAD:
+			wpadURL = strings.TrimRight(string(value), "\x00")
+		}
+		opts = opts[2+int(opts[1]):]
+	}
+
+	if msgType != dhcpAck {
+		wpadURL = ""
+		return
+	}
+	ok = true
+	if wpadURL == "" {
+		err = fmt.Errorf("dhcp: no WPAD option (252) in DHCPACK")
+	}
+	return
+}
+
+// leaseKeys はリースファイルでWPADのURLを表すオプション名
+var leaseKeys = []string{"wpad", "wpad-url", "wpad_url", "unknown-252", "option_252", "dhcp4.option_252"}
+
+// LeaseURL は dhclient または NetworkManager のリースファイルからWPADのURLを読み込む
+// dhclient:       option wpad "http://wpad.example.com/wpad.dat";
+// NetworkManager: wpad=http://wpad.example.com/wpad.dat
+// 複数のリースがある場合は最後のものを返す
+func LeaseURL(leaseFile string) (wpadURL string, err error) {
+	var f *os.File
+	f, err = os.Open(leaseFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var key, value string
+		if strings.HasPrefix(line, "option ") {
+			fields := strings.SplitN(strings.TrimPrefix(line, "option "), " ", 2)
+			if len(fields) < 2 {
+				continue
+			}
+			key = fields[0]
+			value = strings.Trim(strings.TrimSuffix(strings.TrimSpace(fields[1]), ";"), `"`)
+		} else if i := strings.Index(line, "="); i > 0 {
+			key = strings.TrimSpace(line[:i])
+			value = strings.TrimSpace(line[i+1:])
+		} else {
+			continue
+		}
+		if subIsLeaseKey(key) && value != "" {
+			wpadURL = strings.TrimRight(value, "\x00")
+		}
+	}
+	err = scanner.Err()
+	if err == nil && wpadURL == "" {
+		err = fmt.Errorf("%s: no WPAD option (252)", leaseFile)
+	}
+	return
+}
+
+func subIsLeaseKey(key string) (r bool) {
+	key = strings.ToLower(key)
+	for _, k := range leaseKeys {
+		if k == key {
+			r = true
+			break
+		}
+	}
+	return
+}
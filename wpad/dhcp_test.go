@@ -0,0 +1,138 @@
+package wpad
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeDHCP はDHCPINFORMにWPADのURLを含むDHCPACKを返すDHCPサーバの代わり
+func fakeDHCP(t *testing.T, wpadURL string) (addr string) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			if n < dhcpHeaderLen+4 || req[0] != bootRequest ||
+				!bytes.Contains(req[dhcpHeaderLen+4:], []byte{optMessageType, 1, dhcpInform}) ||
+				!bytes.Contains(req[dhcpHeaderLen+4:], []byte{optParamRequest, 1, optWPAD}) {
+				continue
+			}
+			// DHCPINFORMの ciaddr はクライアントのアドレスでなければならない (RFC 2131)
+			if !net.IP(req[12:16]).Equal(from.(*net.UDPAddr).IP) {
+				continue
+			}
+
+			// 別のトランザクションへの応答は無視されなければならない
+			other := append([]byte{}, req[:dhcpHeaderLen+4]...)
+			other[0] = bootReply
+			other[4] ^= 0xff
+			other = append(other, optMessageType, 1, dhcpAck, optWPAD, 4, 'x', 'x', 'x', 'x', optEnd)
+			conn.WriteTo(other, from)
+
+			resp := append([]byte{}, req[:dhcpHeaderLen+4]...)
+			resp[0] = bootReply
+			resp = append(resp, optMessageType, 1, dhcpAck, optPad)
+			resp = append(resp, optWPAD, byte(len(wpadURL)+1))
+			resp = append(resp, wpadURL...)
+			resp = append(resp, 0, optEnd)
+			conn.WriteTo(resp, from)
+		}
+	}()
+
+	addr = conn.LocalAddr().String()
+	return
+}
+
+func TestInform(t *testing.T) {
+	want := "http://wpad.example.com/wpad.dat"
+	c := &DHCPClient{
+		Server:    fakeDHCP(t, want),
+		LocalAddr: "127.0.0.1:0",
+		Timeout:   2 * time.Second,
+	}
+	got, err := c.Inform(context.Background())
+	if err != nil || got != want {
+		t.Errorf("Inform() = %v, %v; want %v, nil", got, err, want)
+	}
+}
+
+func TestBindError(t *testing.T) {
+	err := bindError("0.0.0.0:68", &net.OpError{Op: "listen", Net: "udp4", Err: os.NewSyscallError("bind", syscall.EACCES)})
+	if err == nil || !strings.Contains(err.Error(), "not permitted") || !strings.Contains(err.Error(), "port 68") {
+		t.Errorf("bindError(EACCES) = %v; want an error explaining port 68 needs permission", err)
+	}
+	other := errors.New("address already in use")
+	if err = bindError("0.0.0.0:68", other); err != other {
+		t.Errorf("bindError(other) = %v; want %v", err, other)
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	c := &DHCPClient{LocalAddr: "127.0.0.1:0"}
+	ip, _, err := c.clientAddr()
+	if err != nil || !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("clientAddr() = %v, _, %v; want 127.0.0.1, _, nil", ip, err)
+	}
+}
+
+func TestInformTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c := &DHCPClient{
+		Server:    conn.LocalAddr().String(),
+		LocalAddr: "127.0.0.1:0",
+		Timeout:   100 * time.Millisecond,
+	}
+	if _, err = c.Inform(context.Background()); err == nil {
+		t.Errorf("Inform() = _, nil; want !nil")
+	}
+}
+
+func TestLeaseURL(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "lease")
+	pats := map[string]string{
+		// dhclient
+		"lease {\n  interface \"eth0\";\n  option wpad \"http://old.example.com/wpad.dat\";\n}\n" +
+			"lease {\n  interface \"eth0\";\n  option wpad \"http://wpad.example.com/wpad.dat\";\n}\n": "http://wpad.example.com/wpad.dat",
+		"lease {\n  option unknown-252 \"http://wpad.example.com/wpad.dat\";\n}\n": "http://wpad.example.com/wpad.dat",
+		// NetworkManager
+		"[dhcp4]\ndhcp_lease_time=86400\nwpad=http://wpad.example.com/wpad.dat\n": "http://wpad.example.com/wpad.dat",
+	}
+	for lease, want := range pats {
+		if err := os.WriteFile(leaseFile, []byte(lease), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LeaseURL(leaseFile)
+		if err != nil || got != want {
+			t.Errorf("LeaseURL(%q) = %v, %v; want %v, nil", lease, got, err, want)
+		}
+	}
+
+	if err := os.WriteFile(leaseFile, []byte("lease {\n  option routers 10.0.0.1;\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LeaseURL(leaseFile); err == nil {
+		t.Errorf("LeaseURL() = _, nil; want !nil")
+	}
+}
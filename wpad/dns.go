@@ -0,0 +1,102 @@
+// Package wpad はWeb Proxy Auto-Discovery (WPAD) でPACファイルの場所を探すパッケージ
+package wpad
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bunji2/findproxy/pac"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ResolvConf は検索ドメインを読み込む resolv.conf の既定のパス
+const ResolvConf = "/etc/resolv.conf"
+
+// SearchDomains は resolv.conf の search 行と domain 行から検索ドメインを順番に返す
+func SearchDomains(resolvConf string) (domains []string, err error) {
+	var f *os.File
+	f, err = os.Open(resolvConf)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var search, domain []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "search":
+			// 最後に現れた search 行が有効
+			search = fields[1:]
+		case "domain":
+			domain = fields[1:2]
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return
+	}
+
+	// search 行がある場合は domain 行より優先される
+	domains = search
+	if len(domains) < 1 {
+		domains = domain
+	}
+	return
+}
+
+// Candidates は検索ドメインを上位に辿ったWPADの候補URLを順番に返す
+// "a.b.example.com" => http://wpad.a.b.example.com/wpad.dat, http://wpad.b.example.com/wpad.dat, http://wpad.example.com/wpad.dat
+// 誰でも登録できる wpad.co.jp のような名前でPACファイルを乗っ取られないよう、
+// Public Suffix List で求めた登録可能なドメイン (例: foo.co.jp) より上には辿らない
+func Candidates(domains []string) (r []string) {
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(domain), ".")
+		registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+		if err != nil {
+			// co.jp のような公開サフィックスそのものや1つのラベルだけのドメイン
+			continue
+		}
+		labels := strings.Split(domain, ".")
+		for i := 0; i < len(labels); i++ {
+			parent := strings.Join(labels[i:], ".")
+			if len(parent) < len(registrable) {
+				break
+			}
+			u := fmt.Sprintf("http://wpad.%s/wpad.dat", parent)
+			if !seen[u] {
+				seen[u] = true
+				r = append(r, u)
+			}
+		}
+	}
+	return
+}
+
+// DiscoverDNS は候補URLを順番に取得し、最初に取得できたPACファイルと、そのURLを返す
+func DiscoverDNS(ctx context.Context, f *pac.Fetcher, candidates []string, opts ...pac.Option) (p *pac.PAC, used string, err error) {
+	if len(candidates) < 1 {
+		err = fmt.Errorf("wpad: no search domain")
+		return
+	}
+	for _, u := range candidates {
+		p, err = f.Load(ctx, u, opts...)
+		if err == nil {
+			used = u
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	err = fmt.Errorf("wpad: no PAC file found in %d candidates: %v", len(candidates), err)
+	return
+}
@@ -0,0 +1,95 @@
+package wpad
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bunji2/findproxy/pac"
+)
+
+func TestSearchDomains(t *testing.T) {
+	resolvConf := filepath.Join(t.TempDir(), "resolv.conf")
+	pats := map[string][]string{
+		"nameserver 127.0.0.53\nsearch a.example.com example.net\n":         {"a.example.com", "example.net"},
+		"domain corp.example.com\nnameserver 127.0.0.53\n":                  {"corp.example.com"},
+		"domain corp.example.com\nsearch x.example.com\nsearch y.example\n": {"y.example"},
+		"nameserver 127.0.0.53\n":                                           nil,
+	}
+	for conf, want := range pats {
+		if err := os.WriteFile(resolvConf, []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := SearchDomains(resolvConf)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("SearchDomains(%q) = %v, %v; want %v, nil", conf, got, err, want)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	got := Candidates([]string{"a.b.Example.com.", "b.example.com", "localdomain"})
+	want := []string{
+		"http://wpad.a.b.example.com/wpad.dat",
+		"http://wpad.b.example.com/wpad.dat",
+		"http://wpad.example.com/wpad.dat",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates() = %v; want %v", got, want)
+	}
+
+	// 公開サフィックス (co.jp, com.au) の直下は候補にしない
+	got = Candidates([]string{"a.foo.co.jp", "foo.com.au", "co.jp", "github.io", "x.example.github.io"})
+	want = []string{
+		"http://wpad.a.foo.co.jp/wpad.dat",
+		"http://wpad.foo.co.jp/wpad.dat",
+		"http://wpad.foo.com.au/wpad.dat",
+		"http://wpad.x.example.github.io/wpad.dat",
+		"http://wpad.example.github.io/wpad.dat",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates() = %v; want %v", got, want)
+	}
+}
+
+func TestDiscoverDNS(t *testing.T) {
+	// wpad.example.com だけがPACファイルを返すWPADサーバの代わり
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "wpad.example.com" || r.URL.Path != "/wpad.dat" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", pac.MIMEType)
+		w.Write([]byte(`function FindProxyForURL(url, host) { return "PROXY proxy.example.com:8080"; }`))
+	}))
+	defer ts.Close()
+
+	// 全てのホスト名をテスト用のサーバに接続する
+	f := &pac.Fetcher{Client: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, ts.Listener.Addr().String())
+		},
+	}}}
+
+	ctx := context.Background()
+	candidates := Candidates([]string{"a.b.example.com"})
+	p, used, err := DiscoverDNS(ctx, f, candidates)
+	if err != nil || used != "http://wpad.example.com/wpad.dat" {
+		t.Fatalf("DiscoverDNS() = _, %v, %v; want _, http://wpad.example.com/wpad.dat, nil", used, err)
+	}
+	got, _ := p.FindProxyForURL(ctx, "http://www.example.org/")
+	if got != "PROXY proxy.example.com:8080" {
+		t.Errorf("FindProxyForURL() = %v; want PROXY proxy.example.com:8080", got)
+	}
+
+	_, _, err = DiscoverDNS(ctx, f, Candidates([]string{"example.net"}))
+	if err == nil {
+		t.Errorf("DiscoverDNS(example.net) = _, _, nil; want !nil")
+	}
+}